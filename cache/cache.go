@@ -5,7 +5,7 @@ type Cache interface {
 	IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int)
 	// Cache(p *Packet) []*Packet
 	CacheFiveTuple(f *FiveTuple) []*FiveTuple
-	InvalidateFiveTuple(f *FiveTuple)
+	InvalidateFiveTuple(f *FiveTuple) // does nothing if f is not cached
 	Clear()
 	StatString() string
 
@@ -13,6 +13,40 @@ type Cache interface {
 	ParameterString() string
 }
 
+// StatResetter is implemented by caches which count statistics shown in StatString()
+type StatResetter interface {
+	ResetStat()
+}
+
+// ResetStat resets statistics of the cache if it has
+func ResetStat(c Cache) {
+	if r, ok := c.(StatResetter); ok {
+		r.ResetStat()
+	}
+}
+
+// PacketObserver is implemented by caches which depend on packets themselves (e.g. time of packets),
+// not only on FiveTuples looked up. ObservePacket is called for every packet before the lookup,
+// f is the key of the packet. It returns FiveTuples evicted by the observation (e.g. by prefetches).
+type PacketObserver interface {
+	ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple
+}
+
+// ObservePacket lets the cache observe the packet if it is PacketObserver, and returns evicted FiveTuples
+func ObservePacket(c Cache, p *Packet, f *FiveTuple) []*FiveTuple {
+	if o, ok := c.(PacketObserver); ok {
+		return o.ObservePacket(p, f)
+	}
+
+	return []*FiveTuple{}
+}
+
+// VictimPeeker is implemented by caches which can tell the entry to be evicted when f is cached,
+// without changing their state. ok is false if no entry is evicted (f is cached or there is a free entry).
+type VictimPeeker interface {
+	PeekVictim(f *FiveTuple) (victim *FiveTuple, ok bool)
+}
+
 func AccessCache(c Cache, p *Packet) bool {
 	hit, _ := c.IsCached(p, true)
 	return hit
@@ -4,42 +4,171 @@ import (
 	"fmt"
 )
 
+// CacheWithLookAhead prefetches FiveTuples predicted by Prefetchers into InnerCache
 type CacheWithLookAhead struct {
-	InnerCache Cache
+	InnerCache  Cache
+	Prefetchers []Prefetcher
+
+	Misses        uint           // lookups missed
+	PrefetchStats []PrefetchStat // of each prefetcher
+
+	prefetched map[FiveTuple]int // prefetched entries not used yet, to index of the prefetcher
+}
+
+type PrefetchStat struct {
+	Issued    uint // FiveTuples cached by prefetch
+	Useful    uint // prefetched entries hit before eviction
+	Polluting uint // prefetched entries evicted before first use
+}
+
+func prefetchRatio(part, total uint) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) / float64(total)
+}
+
+// prefetchStatString shows accuracy (useful / issued), coverage (misses removed by prefetch / misses without prefetch)
+// and pollution (polluting / issued)
+func prefetchStatString(stat PrefetchStat, misses uint) string {
+	return fmt.Sprintf("\"Issued\": %d, \"Useful\": %d, \"Polluting\": %d, \"Accuracy\": %v, \"Coverage\": %v, \"Pollution\": %v",
+		stat.Issued, stat.Useful, stat.Polluting,
+		prefetchRatio(stat.Useful, stat.Issued), prefetchRatio(stat.Useful, stat.Useful+misses), prefetchRatio(stat.Polluting, stat.Issued))
 }
 
 func (c *CacheWithLookAhead) StatString() string {
-	return ""
+	total := PrefetchStat{}
+
+	for _, stat := range c.PrefetchStats {
+		total.Issued += stat.Issued
+		total.Useful += stat.Useful
+		total.Polluting += stat.Polluting
+	}
+
+	str := fmt.Sprintf("{\"Misses\": %d, %s, \"Prefetchers\": [", c.Misses, prefetchStatString(total, c.Misses))
+
+	for i, stat := range c.PrefetchStats {
+		if i != 0 {
+			str += ", "
+		}
+
+		// coverage of each prefetcher is against misses without any prefetch
+		str += fmt.Sprintf("{\"Type\": \"%s\", %s}", c.Prefetchers[i].Description(), prefetchStatString(stat, c.Misses+total.Useful-stat.Useful))
+	}
+
+	str += "]"
+
+	if innerStat := c.InnerCache.StatString(); innerStat != "" {
+		str += ", \"InnerCache\": " + innerStat
+	}
+
+	return str + "}"
+}
+
+func (c *CacheWithLookAhead) ResetStat() {
+	c.Misses = 0
+
+	for i := range c.PrefetchStats {
+		c.PrefetchStats[i] = PrefetchStat{}
+	}
+
+	ResetStat(c.InnerCache)
+}
+
+func (c *CacheWithLookAhead) ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple {
+	evictedFiveTuples := ObservePacket(c.InnerCache, p, f)
+	c.evicted(evictedFiveTuples)
+
+	for i, prefetcher := range c.Prefetchers {
+		evictedFiveTuples = append(evictedFiveTuples, c.prefetch(i, prefetcher.ObservePacket(p, f))...)
+	}
+
+	return evictedFiveTuples
 }
 
 func (c *CacheWithLookAhead) IsCached(p *Packet, update bool) (bool, *int) {
-	return c.InnerCache.IsCached(p, update)
+	return c.IsCachedWithFiveTuple(p.FiveTuple(), update)
 }
 
 func (c *CacheWithLookAhead) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
-	return c.InnerCache.IsCachedWithFiveTuple(f, update)
+	hit, layerIdx := c.InnerCache.IsCachedWithFiveTuple(f, update)
+
+	if update {
+		if idx, prefetched := c.prefetched[*f]; prefetched {
+			if hit {
+				c.PrefetchStats[idx].Useful += 1
+			} else {
+				// removed by InnerCache itself (e.g. timeout)
+				c.PrefetchStats[idx].Polluting += 1
+			}
+
+			delete(c.prefetched, *f)
+		}
+
+		if !hit {
+			c.Misses += 1
+		}
+	}
+
+	return hit, layerIdx
 }
 
-func (c *CacheWithLookAhead) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
-	evictedFiveTuples := c.InnerCache.CacheFiveTuple(f)
+// evicted counts prefetched entries evicted before first use
+func (c *CacheWithLookAhead) evicted(evictedFiveTuples []*FiveTuple) {
+	for _, f := range evictedFiveTuples {
+		if idx, prefetched := c.prefetched[*f]; prefetched {
+			c.PrefetchStats[idx].Polluting += 1
+			delete(c.prefetched, *f)
+		}
+	}
+}
+
+// prefetch caches FiveTuples not cached yet, and returns evicted FiveTuples
+func (c *CacheWithLookAhead) prefetch(prefetcherIdx int, fiveTuples []FiveTuple) []*FiveTuple {
+	evictedFiveTuples := []*FiveTuple{}
 
-	if f.Proto == IP_TCP {
-		swapped := (*f).SwapSrcAndDst()
+	for i := range fiveTuples {
+		f := &fiveTuples[i]
 
-		if cached, _ := c.InnerCache.IsCachedWithFiveTuple(&swapped, false); !cached {
-			replaced_by_lookahead := c.InnerCache.CacheFiveTuple(&swapped)
-			evictedFiveTuples = append(evictedFiveTuples, replaced_by_lookahead...)
+		if cached, _ := c.InnerCache.IsCachedWithFiveTuple(f, false); cached {
+			continue
 		}
+
+		replacedByPrefetch := c.InnerCache.CacheFiveTuple(f)
+		c.evicted(replacedByPrefetch)
+		evictedFiveTuples = append(evictedFiveTuples, replacedByPrefetch...)
+
+		// InnerCache may not cache f, e.g. admission is rejected
+		if cached, _ := c.InnerCache.IsCachedWithFiveTuple(f, false); cached {
+			c.prefetched[*f] = prefetcherIdx
+			c.PrefetchStats[prefetcherIdx].Issued += 1
+		}
+	}
+
+	return evictedFiveTuples
+}
+
+func (c *CacheWithLookAhead) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	delete(c.prefetched, *f)
+
+	evictedFiveTuples := c.InnerCache.CacheFiveTuple(f)
+	c.evicted(evictedFiveTuples)
+
+	for i, prefetcher := range c.Prefetchers {
+		evictedFiveTuples = append(evictedFiveTuples, c.prefetch(i, prefetcher.Miss(f))...)
 	}
 
 	return evictedFiveTuples
 }
 
 func (c *CacheWithLookAhead) InvalidateFiveTuple(f *FiveTuple) {
+	delete(c.prefetched, *f)
 	c.InnerCache.InvalidateFiveTuple(f)
 }
 
 func (c *CacheWithLookAhead) Clear() {
+	c.prefetched = map[FiveTuple]int{}
 	c.InnerCache.Clear()
 }
 
@@ -48,5 +177,24 @@ func (c *CacheWithLookAhead) Description() string {
 }
 
 func (c *CacheWithLookAhead) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"InnerCache\": %s}", c.Description(), c.InnerCache.ParameterString())
+	str := fmt.Sprintf("{\"Type\": \"%s\", \"Prefetchers\": [", c.Description())
+
+	for i, prefetcher := range c.Prefetchers {
+		if i != 0 {
+			str += ", "
+		}
+
+		str += prefetcher.ParameterString()
+	}
+
+	return str + fmt.Sprintf("], \"InnerCache\": %s}", c.InnerCache.ParameterString())
+}
+
+func NewCacheWithLookAhead(innerCache Cache, prefetchers []Prefetcher) *CacheWithLookAhead {
+	return &CacheWithLookAhead{
+		InnerCache:    innerCache,
+		Prefetchers:   prefetchers,
+		PrefetchStats: make([]PrefetchStat, len(prefetchers)),
+		prefetched:    map[FiveTuple]int{},
+	}
 }
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// CacheWithThresholdAdmission caches a flow into InnerCache only after the flow proves itself, like flow offloads of hardware:
+// its packets or bytes reach the threshold (Packets, Bytes), or it is sampled by sample and hold
+// (Estan and Varghese, "New Directions in Traffic Measurement and Accounting", SIGCOMM '02),
+// where each byte is sampled with SampleProbability. 0 disables each condition.
+// Packets and bytes of flows for the threshold are counted exactly or by Count-Min sketch,
+// while traffic of admitted flows in statistics is always counted exactly.
+//
+// A rejected FiveTuple is not cached, so it is not returned as evicted, as CacheWithTinyLFUAdmission without the window.
+// In MultiLayerCache, write-back policies pass only evicted FiveTuples to the next layer;
+// use WriteThrough to cache rejected FiveTuples in the next layer.
+type CacheWithThresholdAdmission struct {
+	InnerCache        Cache
+	Packets           uint
+	Bytes             uint
+	SampleProbability float64
+	Seed              int64
+
+	Admitted uint // number of FiveTuples cached into InnerCache
+	Rejected uint
+
+	// traffic of all flows and of admitted flows, including packets before admission
+	TotalPackets    uint
+	TotalBytes      uint
+	AdmittedPackets uint
+	AdmittedBytes   uint
+
+	counter       TrafficCounter
+	traffic       exactTrafficCounter    // for statistics
+	admittedFlows map[FiveTuple]struct{} // flows admitted at least once
+	sampled       map[FiveTuple]struct{} // flows held by sample and hold, until they are cached
+	rng           *rand.Rand
+}
+
+// TrafficCounter counts packets and bytes of each flow
+type TrafficCounter interface {
+	add(f *FiveTuple, bytes uint32)
+	count(f *FiveTuple) (packets, bytes uint)
+	ParameterString() string
+}
+
+type exactTrafficCounter map[FiveTuple]struct{ packets, bytes uint }
+
+func (c exactTrafficCounter) add(f *FiveTuple, bytes uint32) {
+	counts := c[*f]
+	counts.packets += 1
+	counts.bytes += uint(bytes)
+	c[*f] = counts
+}
+
+func (c exactTrafficCounter) count(f *FiveTuple) (uint, uint) {
+	counts := c[*f]
+	return counts.packets, counts.bytes
+}
+
+func (c exactTrafficCounter) ParameterString() string {
+	return "{\"Type\": \"Exact\"}"
+}
+
+type sketchTrafficCounter struct {
+	packets, bytes *countMinSketch
+}
+
+func (c *sketchTrafficCounter) add(f *FiveTuple, bytes uint32) {
+	c.packets.add(f, 1)
+	c.bytes.add(f, bytes)
+}
+
+func (c *sketchTrafficCounter) count(f *FiveTuple) (uint, uint) {
+	return c.packets.estimate(f), c.bytes.estimate(f)
+}
+
+func (c *sketchTrafficCounter) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"Sketch\", \"Width\": %d, \"Depth\": %d}", c.packets.Width, c.packets.Depth)
+}
+
+// NewExactTrafficCounter returns TrafficCounter keeping counters of all flows
+func NewExactTrafficCounter() TrafficCounter {
+	return exactTrafficCounter{}
+}
+
+// NewSketchTrafficCounter returns TrafficCounter by Count-Min sketch, which overestimates counts
+func NewSketchTrafficCounter(width, depth uint) TrafficCounter {
+	return &sketchTrafficCounter{
+		packets: newCountMinSketch(width, depth, math.MaxUint32),
+		bytes:   newCountMinSketch(width, depth, math.MaxUint32),
+	}
+}
+
+func trafficShare(part, total uint) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) / float64(total)
+}
+
+func (c *CacheWithThresholdAdmission) StatString() string {
+	str := fmt.Sprintf("{\"Admitted\": %d, \"Rejected\": %d, \"AdmittedFlows\": %d, \"AdmittedPackets\": %d, \"AdmittedBytes\": %d, \"AdmittedPacketShare\": %v, \"AdmittedByteShare\": %v",
+		c.Admitted, c.Rejected, len(c.admittedFlows), c.AdmittedPackets, c.AdmittedBytes,
+		trafficShare(c.AdmittedPackets, c.TotalPackets), trafficShare(c.AdmittedBytes, c.TotalBytes))
+
+	if innerStat := c.InnerCache.StatString(); innerStat != "" {
+		str += ", \"InnerCache\": " + innerStat
+	}
+
+	return str + "}"
+}
+
+func (c *CacheWithThresholdAdmission) ResetStat() {
+	c.Admitted = 0
+	c.Rejected = 0
+	c.TotalPackets = 0
+	c.TotalBytes = 0
+	c.AdmittedPackets = 0
+	c.AdmittedBytes = 0
+	c.traffic = exactTrafficCounter{}
+
+	ResetStat(c.InnerCache)
+}
+
+func (c *CacheWithThresholdAdmission) ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple {
+	c.counter.add(f, p.Len)
+	c.traffic.add(f, p.Len)
+
+	c.TotalPackets += 1
+	c.TotalBytes += uint(p.Len)
+
+	if _, admitted := c.admittedFlows[*f]; admitted {
+		c.AdmittedPackets += 1
+		c.AdmittedBytes += uint(p.Len)
+	}
+
+	if c.SampleProbability != 0 {
+		// the packet is sampled if any of its bytes is sampled
+		if c.rng.Float64() < 1-math.Pow(1-c.SampleProbability, float64(p.Len)) {
+			c.sampled[*f] = struct{}{}
+		}
+	}
+
+	return ObservePacket(c.InnerCache, p, f)
+}
+
+func (c *CacheWithThresholdAdmission) passes(f *FiveTuple) bool {
+	if _, sampled := c.sampled[*f]; sampled {
+		return true
+	}
+
+	packets, bytes := c.counter.count(f)
+
+	return (c.Packets != 0 && c.Packets <= packets) || (c.Bytes != 0 && c.Bytes <= bytes)
+}
+
+func (c *CacheWithThresholdAdmission) IsCached(p *Packet, update bool) (bool, *int) {
+	return c.InnerCache.IsCached(p, update)
+}
+
+func (c *CacheWithThresholdAdmission) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	return c.InnerCache.IsCachedWithFiveTuple(f, update)
+}
+
+func (c *CacheWithThresholdAdmission) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	if hit, _ := c.InnerCache.IsCachedWithFiveTuple(f, false); hit {
+		return []*FiveTuple{}
+	}
+
+	if !c.passes(f) {
+		// f is not cached, nothing is evicted
+		c.Rejected += 1
+		return []*FiveTuple{}
+	}
+
+	if _, admitted := c.admittedFlows[*f]; !admitted {
+		c.admittedFlows[*f] = struct{}{}
+
+		// packets before admission are carried by the flow too
+		packets, bytes := c.traffic.count(f)
+		c.AdmittedPackets += packets
+		c.AdmittedBytes += bytes
+	}
+
+	delete(c.sampled, *f)
+	c.Admitted += 1
+
+	return c.InnerCache.CacheFiveTuple(f)
+}
+
+func (c *CacheWithThresholdAdmission) InvalidateFiveTuple(f *FiveTuple) {
+	c.InnerCache.InvalidateFiveTuple(f)
+}
+
+// Clear clears cached entries, counters of flows are kept
+func (c *CacheWithThresholdAdmission) Clear() {
+	c.InnerCache.Clear()
+}
+
+func (c *CacheWithThresholdAdmission) Description() string {
+	return "CacheWithThresholdAdmission[" + c.InnerCache.Description() + "]"
+}
+
+func (c *CacheWithThresholdAdmission) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Packets\": %d, \"Bytes\": %d, \"SampleAndHold\": %v, \"Seed\": %d, \"Counter\": %s, \"InnerCache\": %s}",
+		c.Description(), c.Packets, c.Bytes, c.SampleProbability, c.Seed, c.counter.ParameterString(), c.InnerCache.ParameterString())
+}
+
+func NewCacheWithThresholdAdmission(innerCache Cache, counter TrafficCounter, packets, bytes uint, sampleProbability float64, seed int64) *CacheWithThresholdAdmission {
+	if packets == 0 && bytes == 0 && sampleProbability == 0 {
+		panic("CacheWithThresholdAdmission needs Packets, Bytes or SampleAndHold")
+	}
+
+	if sampleProbability < 0 || 1 < sampleProbability {
+		panic(fmt.Sprintf("probability of sample and hold must be in [0, 1], got: %v", sampleProbability))
+	}
+
+	return &CacheWithThresholdAdmission{
+		InnerCache:        innerCache,
+		Packets:           packets,
+		Bytes:             bytes,
+		SampleProbability: sampleProbability,
+		Seed:              seed,
+		counter:           counter,
+		traffic:           exactTrafficCounter{},
+		admittedFlows:     map[FiveTuple]struct{}{},
+		sampled:           map[FiveTuple]struct{}{},
+		rng:               rand.New(rand.NewSource(seed)),
+	}
+}
@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// CacheWithTimeout expires entries of InnerCache by time like OpenFlow and conntrack tables:
+// an entry whose last hit is older than IdleTimeout, or whose install time is older than HardTimeout.
+// Expired entries are found lazily when they are looked up, and by sweeps every SweepInterval seconds if it is positive.
+// Time advances by packets given to ObservePacket or IsCached. 0 disables each timeout.
+// Entries installed inside InnerCache are tracked from their first hit.
+type CacheWithTimeout struct {
+	InnerCache    Cache
+	IdleTimeout   float64
+	HardTimeout   float64
+	SweepInterval float64
+
+	IdleExpirations uint
+	HardExpirations uint
+	Evictions       uint // by capacity of InnerCache
+	Sweeps          uint
+
+	entries   map[FiveTuple]*list.Element
+	installed *list.List // of *cacheWithTimeoutEntry in order of install, to sweep in deterministic order
+	now       float64
+	nextSweep float64
+	started   bool
+}
+
+type cacheWithTimeoutEntry struct {
+	FiveTuple FiveTuple
+	Installed float64
+	LastHit   float64
+}
+
+func (c *CacheWithTimeout) StatString() string {
+	str := fmt.Sprintf("{\"IdleExpirations\": %d, \"HardExpirations\": %d, \"Evictions\": %d, \"Sweeps\": %d",
+		c.IdleExpirations, c.HardExpirations, c.Evictions, c.Sweeps)
+
+	if innerStat := c.InnerCache.StatString(); innerStat != "" {
+		str += ", \"InnerCache\": " + innerStat
+	}
+
+	return str + "}"
+}
+
+func (c *CacheWithTimeout) ResetStat() {
+	c.IdleExpirations = 0
+	c.HardExpirations = 0
+	c.Evictions = 0
+	c.Sweeps = 0
+
+	ResetStat(c.InnerCache)
+}
+
+// advance sets the current time, and sweeps expired entries if a sweep is due
+func (c *CacheWithTimeout) advance(time float64) {
+	c.now = time
+
+	if c.SweepInterval == 0 {
+		return
+	}
+
+	if !c.started {
+		c.started = true
+		c.nextSweep = time + c.SweepInterval
+		return
+	}
+
+	if time < c.nextSweep {
+		return
+	}
+
+	for c.nextSweep <= time {
+		c.nextSweep += c.SweepInterval
+	}
+
+	c.Sweeps += 1
+
+	for el := c.installed.Front(); el != nil; {
+		next := el.Next()
+		c.expireIfTimedOut(el)
+		el = next
+	}
+}
+
+// expireIfTimedOut invalidates the entry if it timed out, and returns whether it was expired
+func (c *CacheWithTimeout) expireIfTimedOut(el *list.Element) bool {
+	entry := el.Value.(*cacheWithTimeoutEntry)
+
+	switch {
+	case c.HardTimeout != 0 && c.HardTimeout <= c.now-entry.Installed:
+		c.HardExpirations += 1
+	case c.IdleTimeout != 0 && c.IdleTimeout <= c.now-entry.LastHit:
+		c.IdleExpirations += 1
+	default:
+		return false
+	}
+
+	c.InnerCache.InvalidateFiveTuple(&entry.FiveTuple)
+	c.remove(el)
+
+	return true
+}
+
+func (c *CacheWithTimeout) remove(el *list.Element) {
+	entry := c.installed.Remove(el).(*cacheWithTimeoutEntry)
+	delete(c.entries, entry.FiveTuple)
+}
+
+func (c *CacheWithTimeout) ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple {
+	c.advance(p.Time)
+
+	evictedFiveTuples := ObservePacket(c.InnerCache, p, f)
+	c.evicted(evictedFiveTuples)
+
+	return evictedFiveTuples
+}
+
+func (c *CacheWithTimeout) IsCached(p *Packet, update bool) (bool, *int) {
+	c.advance(p.Time)
+	return c.IsCachedWithFiveTuple(p.FiveTuple(), update)
+}
+
+func (c *CacheWithTimeout) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	if el, tracked := c.entries[*f]; tracked && c.expireIfTimedOut(el) {
+		return false, nil
+	}
+
+	hit, layerIdx := c.InnerCache.IsCachedWithFiveTuple(f, update)
+
+	if update {
+		el, tracked := c.entries[*f]
+
+		switch {
+		case hit && tracked:
+			el.Value.(*cacheWithTimeoutEntry).LastHit = c.now
+		case hit && !tracked:
+			// installed inside InnerCache (e.g. prefetched, or promoted from a lower layer)
+			c.track(f)
+		case !hit && tracked:
+			// evicted inside InnerCache
+			c.remove(el)
+		}
+	}
+
+	return hit, layerIdx
+}
+
+func (c *CacheWithTimeout) track(f *FiveTuple) {
+	c.entries[*f] = c.installed.PushBack(&cacheWithTimeoutEntry{
+		FiveTuple: *f,
+		Installed: c.now,
+		LastHit:   c.now,
+	})
+}
+
+// evicted stops tracking FiveTuples evicted by capacity of InnerCache
+func (c *CacheWithTimeout) evicted(evictedFiveTuples []*FiveTuple) {
+	for _, evicted := range evictedFiveTuples {
+		if el, tracked := c.entries[*evicted]; tracked {
+			c.remove(el)
+		}
+	}
+
+	c.Evictions += uint(len(evictedFiveTuples))
+}
+
+func (c *CacheWithTimeout) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	if el, tracked := c.entries[*f]; tracked {
+		c.expireIfTimedOut(el)
+	}
+
+	evictedFiveTuples := c.InnerCache.CacheFiveTuple(f)
+	c.evicted(evictedFiveTuples)
+
+	// InnerCache may not cache f, e.g. admission is rejected
+	el, tracked := c.entries[*f]
+	if cached, _ := c.InnerCache.IsCachedWithFiveTuple(f, false); !cached {
+		if tracked {
+			c.remove(el)
+		}
+	} else if !tracked {
+		c.track(f)
+	}
+
+	return evictedFiveTuples
+}
+
+func (c *CacheWithTimeout) InvalidateFiveTuple(f *FiveTuple) {
+	if el, tracked := c.entries[*f]; tracked {
+		c.remove(el)
+	}
+
+	c.InnerCache.InvalidateFiveTuple(f)
+}
+
+func (c *CacheWithTimeout) Clear() {
+	c.entries = map[FiveTuple]*list.Element{}
+	c.installed.Init()
+	c.InnerCache.Clear()
+}
+
+func (c *CacheWithTimeout) Description() string {
+	return "CacheWithTimeout[" + c.InnerCache.Description() + "]"
+}
+
+func (c *CacheWithTimeout) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"IdleTimeout\": %v, \"HardTimeout\": %v, \"SweepInterval\": %v, \"InnerCache\": %s}",
+		c.Description(), c.IdleTimeout, c.HardTimeout, c.SweepInterval, c.InnerCache.ParameterString())
+}
+
+func NewCacheWithTimeout(innerCache Cache, idleTimeout, hardTimeout, sweepInterval float64) *CacheWithTimeout {
+	if idleTimeout == 0 && hardTimeout == 0 {
+		panic("CacheWithTimeout needs IdleTimeout or HardTimeout")
+	}
+
+	return &CacheWithTimeout{
+		InnerCache:    innerCache,
+		IdleTimeout:   idleTimeout,
+		HardTimeout:   hardTimeout,
+		SweepInterval: sweepInterval,
+		entries:       map[FiveTuple]*list.Element{},
+		installed:     list.New(),
+	}
+}
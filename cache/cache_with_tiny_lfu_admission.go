@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// counters of the sketch are 4 bit as TinyLFU
+const tinyLFUMaxCount = 15
+
+// W-TinyLFU (Einziger et al., "TinyLFU: A Highly Efficient Cache Admission Policy", ToS '17).
+// A new FiveTuple is cached in the window LRU first. An entry evicted from the window is admitted into InnerCache
+// only if its estimated frequency is higher than the one of the victim of InnerCache, so that flows seen only
+// a few times don't pollute InnerCache. Frequency is counted by Count-Min sketch behind a doorkeeper Bloom filter,
+// which are reset (counters are halved) every SampleSize accesses.
+//
+// The window is not carved out of InnerCache: the cache holds up to Size = WindowSize + size of InnerCache FiveTuples.
+// Without the window, a rejected FiveTuple is never cached, so it is counted only in Rejected, not as evicted,
+// as CacheWithThresholdAdmission. With the window, it is evicted from the window and passed to the next layer of MultiLayerCache.
+//
+// InnerCache must be VictimPeeker: an insertion can't be undone without changing the state of
+// the replacement policy (e.g. ghost entries of ARC), so the victim is compared before caching.
+type CacheWithTinyLFUAdmission struct {
+	InnerCache Cache
+	Window     *FullAssociativeLRUCache // nil if WindowSize is 0
+	WindowSize uint
+	Size       uint // WindowSize + size of InnerCache, 0 if unknown
+	SampleSize uint
+
+	Admitted uint
+	Rejected uint
+	Resets   uint
+
+	sketch     *countMinSketch
+	doorkeeper *bloomFilter
+	accesses   uint // since the last reset
+}
+
+func (c *CacheWithTinyLFUAdmission) StatString() string {
+	str := fmt.Sprintf("{\"Admitted\": %d, \"Rejected\": %d, \"Resets\": %d", c.Admitted, c.Rejected, c.Resets)
+
+	if innerStat := c.InnerCache.StatString(); innerStat != "" {
+		str += ", \"InnerCache\": " + innerStat
+	}
+
+	return str + "}"
+}
+
+func (c *CacheWithTinyLFUAdmission) ResetStat() {
+	c.Admitted = 0
+	c.Rejected = 0
+	c.Resets = 0
+
+	ResetStat(c.InnerCache)
+}
+
+func (c *CacheWithTinyLFUAdmission) ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple {
+	return ObservePacket(c.InnerCache, p, f)
+}
+
+// record counts an access to f, the first access is counted only in doorkeeper
+func (c *CacheWithTinyLFUAdmission) record(f *FiveTuple) {
+	if c.doorkeeper.add(f) {
+		c.sketch.add(f, 1)
+	}
+
+	c.accesses += 1
+
+	if c.SampleSize <= c.accesses {
+		c.sketch.halve()
+		c.doorkeeper.clear()
+		c.accesses = 0
+		c.Resets += 1
+	}
+}
+
+func (c *CacheWithTinyLFUAdmission) estimate(f *FiveTuple) uint {
+	estimation := c.sketch.estimate(f)
+
+	if c.doorkeeper.contains(f) {
+		estimation += 1
+	}
+
+	return estimation
+}
+
+func (c *CacheWithTinyLFUAdmission) IsCached(p *Packet, update bool) (bool, *int) {
+	return c.IsCachedWithFiveTuple(p.FiveTuple(), update)
+}
+
+func (c *CacheWithTinyLFUAdmission) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	if update {
+		c.record(f)
+	}
+
+	if c.Window != nil {
+		if hit, _ := c.Window.IsCachedWithFiveTuple(f, update); hit {
+			return true, nil
+		}
+	}
+
+	return c.InnerCache.IsCachedWithFiveTuple(f, update)
+}
+
+func (c *CacheWithTinyLFUAdmission) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	if hit, _ := c.IsCachedWithFiveTuple(f, false); hit {
+		return []*FiveTuple{}
+	}
+
+	if c.Window == nil {
+		evictedFiveTuples, _ := c.admit(f)
+		return evictedFiveTuples
+	}
+
+	evictedFiveTuples := []*FiveTuple{}
+
+	for _, candidate := range c.Window.CacheFiveTuple(f) {
+		evicted, admitted := c.admit(candidate)
+		evictedFiveTuples = append(evictedFiveTuples, evicted...)
+
+		if !admitted {
+			// evicted from the window
+			evictedFiveTuples = append(evictedFiveTuples, candidate)
+		}
+	}
+
+	return evictedFiveTuples
+}
+
+// admit caches candidate into InnerCache if it is more frequent than the victim,
+// returns FiveTuples evicted from InnerCache and whether candidate is admitted
+func (c *CacheWithTinyLFUAdmission) admit(candidate *FiveTuple) ([]*FiveTuple, bool) {
+	if victim, ok := c.InnerCache.(VictimPeeker).PeekVictim(candidate); ok && c.estimate(candidate) <= c.estimate(victim) {
+		c.Rejected += 1
+		return []*FiveTuple{}, false
+	}
+
+	c.Admitted += 1
+	return c.InnerCache.CacheFiveTuple(candidate), true
+}
+
+func (c *CacheWithTinyLFUAdmission) InvalidateFiveTuple(f *FiveTuple) {
+	if c.Window != nil {
+		c.Window.InvalidateFiveTuple(f)
+	}
+
+	c.InnerCache.InvalidateFiveTuple(f)
+}
+
+// Clear clears cached entries, frequency in the sketch is kept
+func (c *CacheWithTinyLFUAdmission) Clear() {
+	if c.Window != nil {
+		c.Window.Clear()
+	}
+
+	c.InnerCache.Clear()
+}
+
+func (c *CacheWithTinyLFUAdmission) Description() string {
+	return "CacheWithTinyLFUAdmission[" + c.InnerCache.Description() + "]"
+}
+
+func (c *CacheWithTinyLFUAdmission) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d, \"WindowSize\": %d, \"SketchWidth\": %d, \"SketchDepth\": %d, \"SampleSize\": %d, \"DoorkeeperSize\": %d, \"InnerCache\": %s}",
+		c.Description(), c.Size, c.WindowSize, c.sketch.Width, c.sketch.Depth, c.SampleSize, c.doorkeeper.Size, c.InnerCache.ParameterString())
+}
+
+func NewCacheWithTinyLFUAdmission(innerCache Cache, windowSize, sketchWidth, sketchDepth, sampleSize, doorkeeperSize uint) *CacheWithTinyLFUAdmission {
+	if sampleSize == 0 {
+		panic("SampleSize must be positive")
+	}
+
+	if _, ok := innerCache.(VictimPeeker); !ok {
+		panic("InnerCache of CacheWithTinyLFUAdmission must be VictimPeeker")
+	}
+
+	c := &CacheWithTinyLFUAdmission{
+		InnerCache: innerCache,
+		WindowSize: windowSize,
+		SampleSize: sampleSize,
+		sketch:     newCountMinSketch(sketchWidth, sketchDepth, tinyLFUMaxCount),
+		doorkeeper: newBloomFilter(doorkeeperSize),
+	}
+
+	if windowSize != 0 {
+		c.Window = NewFullAssociativeLRUCache(windowSize)
+	}
+
+	return c
+}
@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// d-ary cuckoo hash table (Pagh and Rodler, ESA '01) like exact-match tables of switch ASICs.
+// Each FiveTuple has one candidate slot in each of D tables. When all candidates are used,
+// entries are displaced to their candidates in the next table, up to MaxDisplacements times.
+// An entry left at the end of the displacement path goes to the stash, or is evicted if the stash is full.
+type CuckooHashCache struct {
+	Entries          map[FiveTuple]cuckooHashCacheLocation
+	Size             uint // number of slots in tables, the stash is not included
+	D                uint // number of hash functions (tables)
+	MaxDisplacements uint
+	StashSize        uint
+
+	Insertions        uint
+	Displacements     uint
+	StashInsertions   uint
+	InsertionFailures uint // entries evicted because the displacement path is too long and the stash is full
+
+	tables [][]cuckooHashCacheSlot // len(tables) == D, each len == Size / D
+	stash  []FiveTuple
+}
+
+type cuckooHashCacheLocation struct {
+	Table int // -1 if in the stash
+	Idx   uint
+}
+
+type cuckooHashCacheSlot struct {
+	FiveTuple FiveTuple
+	Used      bool
+}
+
+func (cache *CuckooHashCache) StatString() string {
+	return fmt.Sprintf("{\"Entries\": %d, \"Stash\": %d, \"Insertions\": %d, \"Displacements\": %d, \"StashInsertions\": %d, \"InsertionFailures\": %d}",
+		len(cache.Entries), len(cache.stash), cache.Insertions, cache.Displacements, cache.StashInsertions, cache.InsertionFailures)
+}
+
+func (cache *CuckooHashCache) ResetStat() {
+	cache.Insertions = 0
+	cache.Displacements = 0
+	cache.StashInsertions = 0
+	cache.InsertionFailures = 0
+}
+
+func (cache *CuckooHashCache) AssertImmutableCondition() {
+	if int(cache.Size+cache.StashSize) < len(cache.Entries) {
+		panic(fmt.Sprintln("len(cache.Entries):", len(cache.Entries), ", expected: less than or equal to", cache.Size+cache.StashSize))
+	}
+
+	if int(cache.StashSize) < len(cache.stash) {
+		panic(fmt.Sprintln("len(cache.stash):", len(cache.stash), ", expected: less than or equal to", cache.StashSize))
+	}
+}
+
+// idx of the candidate slot for f in the table
+func (cache *CuckooHashCache) slotIdx(f *FiveTuple, table uint) uint {
+	return uint(seededHash(f, uint32(table))) % uint(len(cache.tables[table]))
+}
+
+// placeInEmptySlot places f in an empty candidate slot if any
+func (cache *CuckooHashCache) placeInEmptySlot(f *FiveTuple) bool {
+	for table := uint(0); table < cache.D; table++ {
+		idx := cache.slotIdx(f, table)
+		slot := &cache.tables[table][idx]
+
+		if !slot.Used {
+			*slot = cuckooHashCacheSlot{FiveTuple: *f, Used: true}
+			cache.Entries[*f] = cuckooHashCacheLocation{int(table), idx}
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cache *CuckooHashCache) IsCached(p *Packet, update bool) (bool, *int) {
+	return cache.IsCachedWithFiveTuple(p.FiveTuple(), update)
+}
+
+func (cache *CuckooHashCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	_, hit := cache.Entries[*f]
+	return hit, nil
+}
+
+// CacheFiveTuple may return f itself when f can't be inserted (MaxDisplacements == 0)
+func (cache *CuckooHashCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	cache.AssertImmutableCondition()
+
+	evictedFiveTuples := []*FiveTuple{}
+
+	if hit, _ := cache.IsCachedWithFiveTuple(f, true); hit {
+		return evictedFiveTuples
+	}
+
+	cache.Insertions += 1
+
+	if cache.placeInEmptySlot(f) {
+		return evictedFiveTuples
+	}
+
+	// displace the entry at the candidate, and move it to its candidate in the next table
+	displaced := *f
+	table := uint(0)
+
+	for i := uint(0); i < cache.MaxDisplacements; i++ {
+		idx := cache.slotIdx(&displaced, table)
+		slot := &cache.tables[table][idx]
+
+		victim := slot.FiveTuple
+		delete(cache.Entries, victim)
+
+		slot.FiveTuple = displaced
+		cache.Entries[displaced] = cuckooHashCacheLocation{int(table), idx}
+
+		displaced = victim
+		cache.Displacements += 1
+
+		if cache.placeInEmptySlot(&displaced) {
+			cache.AssertImmutableCondition()
+			return evictedFiveTuples
+		}
+
+		// victim was in this table, so its candidate in the next table is tried
+		table = (table + 1) % cache.D
+	}
+
+	if len(cache.stash) < int(cache.StashSize) {
+		cache.Entries[displaced] = cuckooHashCacheLocation{-1, uint(len(cache.stash))}
+		cache.stash = append(cache.stash, displaced)
+		cache.StashInsertions += 1
+	} else {
+		evictedFiveTuples = append(evictedFiveTuples, &displaced)
+		cache.InsertionFailures += 1
+	}
+
+	cache.AssertImmutableCondition()
+
+	return evictedFiveTuples
+}
+
+// PeekVictim follows the displacement path as CacheFiveTuple does, on a copy of slots overwritten on the path.
+// The victim may be f itself when f can't be inserted.
+func (cache *CuckooHashCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	overwritten := map[cuckooHashCacheLocation]FiveTuple{}
+
+	hasEmptySlot := func(f *FiveTuple) bool {
+		for table := uint(0); table < cache.D; table++ {
+			idx := cache.slotIdx(f, table)
+			if _, ok := overwritten[cuckooHashCacheLocation{int(table), idx}]; !ok && !cache.tables[table][idx].Used {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if hasEmptySlot(f) {
+		return nil, false
+	}
+
+	displaced := *f
+	table := uint(0)
+
+	for i := uint(0); i < cache.MaxDisplacements; i++ {
+		loc := cuckooHashCacheLocation{int(table), cache.slotIdx(&displaced, table)}
+
+		victim, ok := overwritten[loc]
+		if !ok {
+			victim = cache.tables[table][loc.Idx].FiveTuple
+		}
+
+		overwritten[loc] = displaced
+		displaced = victim
+
+		if hasEmptySlot(&displaced) {
+			return nil, false
+		}
+
+		table = (table + 1) % cache.D
+	}
+
+	if len(cache.stash) < int(cache.StashSize) {
+		return nil, false
+	}
+
+	return &displaced, true
+}
+
+func (cache *CuckooHashCache) InvalidateFiveTuple(f *FiveTuple) {
+	loc, hit := cache.Entries[*f]
+
+	if !hit {
+		return
+	}
+
+	delete(cache.Entries, *f)
+
+	if 0 <= loc.Table {
+		cache.tables[loc.Table][loc.Idx] = cuckooHashCacheSlot{}
+		return
+	}
+
+	// move the last entry of the stash to the hole
+	last := len(cache.stash) - 1
+	if int(loc.Idx) != last {
+		cache.stash[loc.Idx] = cache.stash[last]
+		cache.Entries[cache.stash[loc.Idx]] = loc
+	}
+	cache.stash = cache.stash[:last]
+}
+
+func (cache *CuckooHashCache) Clear() {
+	cache.Entries = map[FiveTuple]cuckooHashCacheLocation{}
+	cache.stash = cache.stash[:0]
+
+	for table := range cache.tables {
+		for idx := range cache.tables[table] {
+			cache.tables[table][idx] = cuckooHashCacheSlot{}
+		}
+	}
+}
+
+func (cache *CuckooHashCache) Description() string {
+	return "CuckooHashCache"
+}
+
+func (cache *CuckooHashCache) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d, \"D\": %d, \"MaxDisplacements\": %d, \"StashSize\": %d}",
+		cache.Description(), cache.Size, cache.D, cache.MaxDisplacements, cache.StashSize)
+}
+
+func NewCuckooHashCache(size, d, maxDisplacements, stashSize uint) *CuckooHashCache {
+	if d < 2 {
+		panic("D must be 2 or more")
+	}
+
+	if size%d != 0 {
+		panic("Size must be multiplier of D")
+	}
+
+	tables := make([][]cuckooHashCacheSlot, d)
+	for i := range tables {
+		tables[i] = make([]cuckooHashCacheSlot, size/d)
+	}
+
+	return &CuckooHashCache{
+		Entries:          map[FiveTuple]cuckooHashCacheLocation{},
+		Size:             size,
+		D:                d,
+		MaxDisplacements: maxDisplacements,
+		StashSize:        stashSize,
+		tables:           tables,
+		stash:            make([]FiveTuple, 0, stashSize),
+	}
+}
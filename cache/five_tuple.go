@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"net"
@@ -16,26 +17,59 @@ const (
 	IP_L2TP   IPProtocol = 115
 )
 
-// uint8 + uint32 x 2 + uint16 x2 = 104 byte
+// IPv6 address, IPv4 address is held as IPv4-mapped IPv6 address (::ffff:a.b.c.d)
+type IPAddr [16]byte
+
+// uint8 + IPAddr x 2 + uint16 x 2 = 296 bit
 type FiveTuple struct {
 	Proto            IPProtocol
-	SrcIP, DstIP     uint32
+	SrcIP, DstIP     IPAddr
 	SrcPort, DstPort uint16
 }
 
-func ipToUInt32(ip net.IP) uint32 {
-	if len(ip) == 16 {
-		return binary.BigEndian.Uint32(ip[12:16])
-	}
-	return binary.BigEndian.Uint32(ip)
+var ipv4MappedPrefix = [12]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff}
+
+func ipToIPAddr(ip net.IP) IPAddr {
+	var addr IPAddr
+	copy(addr[:], ip.To16())
+	return addr
 }
 
-func uint32ToIP(nn uint32) net.IP {
-	ip := make(net.IP, 4)
-	binary.BigEndian.PutUint32(ip, nn)
+func (addr IPAddr) IsIPv4() bool {
+	return bytes.Equal(addr[:12], ipv4MappedPrefix[:])
+}
+
+func (addr IPAddr) IP() net.IP {
+	ip := make(net.IP, 16)
+	copy(ip, addr[:])
 	return ip
 }
 
+func (addr IPAddr) String() string {
+	return addr.IP().String()
+}
+
+// IPv4 FiveTuple is serialized in 13 bytes (uint8 + uint32 x 2 + uint16 x 2), otherwise in 37 bytes
+func fiveTupleToBigEndianByteArray(f *FiveTuple) []byte {
+	if f.SrcIP.IsIPv4() && f.DstIP.IsIPv4() {
+		buf := make([]byte, 13)
+		buf[0] = byte(f.Proto)
+		copy(buf[1:5], f.SrcIP[12:16])
+		copy(buf[5:9], f.DstIP[12:16])
+		binary.BigEndian.PutUint16(buf[9:11], f.SrcPort)
+		binary.BigEndian.PutUint16(buf[11:13], f.DstPort)
+		return buf
+	}
+
+	buf := make([]byte, 37)
+	buf[0] = byte(f.Proto)
+	copy(buf[1:17], f.SrcIP[:])
+	copy(buf[17:33], f.DstIP[:])
+	binary.BigEndian.PutUint16(buf[33:35], f.SrcPort)
+	binary.BigEndian.PutUint16(buf[35:37], f.DstPort)
+	return buf
+}
+
 func StrToIPProtocol(proto string) IPProtocol {
 	switch proto {
 	case "ICMP", "icmp":
@@ -64,7 +98,7 @@ func (p *Packet) FiveTuple() *FiveTuple {
 	proto := StrToIPProtocol(p.Proto)
 	switch proto {
 	case IP_TCP, IP_UDP:
-		return &FiveTuple{proto, ipToUInt32(p.SrcIP), ipToUInt32(p.DstIP), p.SrcPort, p.DstPort}
+		return &FiveTuple{proto, ipToIPAddr(p.SrcIP), ipToIPAddr(p.DstIP), p.SrcPort, p.DstPort}
 	// case "icmp":
 	// 	return FiveTuple{p.Proto, p.SrcIP, p.DstIP, 0, 0}
 	default:
@@ -84,5 +118,5 @@ func (f FiveTuple) SwapSrcAndDst() FiveTuple {
 }
 
 func (f FiveTuple) String() string {
-	return fmt.Sprintf("FiveTuple{%v, %v, %v, %v, %v}", f.Proto, uint32ToIP(f.SrcIP), uint32ToIP(f.DstIP), f.SrcPort, f.DstPort)
+	return fmt.Sprintf("FiveTuple{%v, %v, %v, %v, %v}", f.Proto, f.SrcIP, f.DstIP, f.SrcPort, f.DstPort)
 }
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type FlowKeyField int
+
+const (
+	FlowKeyProto FlowKeyField = iota
+	FlowKeySrcIP
+	FlowKeyDstIP
+	FlowKeySrcPort
+	FlowKeyDstPort
+)
+
+func (kf FlowKeyField) String() string {
+	switch kf {
+	case FlowKeyProto:
+		return "Proto"
+	case FlowKeySrcIP:
+		return "SrcIP"
+	case FlowKeyDstIP:
+		return "DstIP"
+	case FlowKeySrcPort:
+		return "SrcPort"
+	case FlowKeyDstPort:
+		return "DstPort"
+	default:
+		panic(fmt.Sprintf("Unknown FlowKeyField value: %d", kf))
+	}
+}
+
+func StringToFlowKeyField(s string) (FlowKeyField, error) {
+	switch s {
+	case "Proto":
+		return FlowKeyProto, nil
+	case "SrcIP":
+		return FlowKeySrcIP, nil
+	case "DstIP":
+		return FlowKeyDstIP, nil
+	case "SrcPort":
+		return FlowKeySrcPort, nil
+	case "DstPort":
+		return FlowKeyDstPort, nil
+	default:
+		return 0, fmt.Errorf("Unknown flow key field: %s", s)
+	}
+}
+
+// FlowKeyExtractor builds a cache key from a packet.
+// Fields which are not used are zero in the key, and IP addresses are masked with prefix length.
+type FlowKeyExtractor struct {
+	Fields        []FlowKeyField
+	SrcPrefixLen  uint // for IPv4 address
+	DstPrefixLen  uint
+	SrcPrefixLen6 uint // for IPv6 address
+	DstPrefixLen6 uint
+	Bidirectional bool // both directions of a flow have the same key
+}
+
+func maskIPAddr(addr IPAddr, prefixLen, prefixLen6 uint) IPAddr {
+	var bits int
+	if addr.IsIPv4() {
+		bits = 96 + int(prefixLen)
+	} else {
+		bits = int(prefixLen6)
+	}
+
+	for i := range addr {
+		switch {
+		case bits <= 8*i:
+			addr[i] = 0
+		case bits < 8*(i+1):
+			addr[i] &= ^byte(0xff >> uint(bits-8*i))
+		}
+	}
+
+	return addr
+}
+
+// Extract returns nil if the packet has no FiveTuple
+func (e *FlowKeyExtractor) Extract(p *Packet) *FiveTuple {
+	f := p.FiveTuple()
+
+	if f == nil {
+		return nil
+	}
+
+	key := FiveTuple{}
+
+	for _, field := range e.Fields {
+		switch field {
+		case FlowKeyProto:
+			key.Proto = f.Proto
+		case FlowKeySrcIP:
+			key.SrcIP = maskIPAddr(f.SrcIP, e.SrcPrefixLen, e.SrcPrefixLen6)
+		case FlowKeyDstIP:
+			key.DstIP = maskIPAddr(f.DstIP, e.DstPrefixLen, e.DstPrefixLen6)
+		case FlowKeySrcPort:
+			key.SrcPort = f.SrcPort
+		case FlowKeyDstPort:
+			key.DstPort = f.DstPort
+		}
+	}
+
+	if e.Bidirectional {
+		cmp := bytes.Compare(key.SrcIP[:], key.DstIP[:])
+		if 0 < cmp || (cmp == 0 && key.DstPort < key.SrcPort) {
+			key = key.SwapSrcAndDst()
+		}
+	}
+
+	return &key
+}
+
+// HasField returns whether keys keep the field
+func (e *FlowKeyExtractor) HasField(field FlowKeyField) bool {
+	for _, f := range e.Fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *FlowKeyExtractor) ParameterString() string {
+	str := "{\"Fields\": ["
+
+	for i, field := range e.Fields {
+		if i != 0 {
+			str += ", "
+		}
+
+		str += fmt.Sprintf("\"%s\"", field.String())
+	}
+
+	str += "], "
+	str += fmt.Sprintf("\"SrcPrefixLen\": %d, \"DstPrefixLen\": %d, \"SrcPrefixLen6\": %d, \"DstPrefixLen6\": %d, \"Bidirectional\": %v}",
+		e.SrcPrefixLen, e.DstPrefixLen, e.SrcPrefixLen6, e.DstPrefixLen6, e.Bidirectional)
+
+	return str
+}
+
+// NewFiveTupleKeyExtractor returns extractor which gives the same key as Packet.FiveTuple()
+func NewFiveTupleKeyExtractor() *FlowKeyExtractor {
+	return &FlowKeyExtractor{
+		Fields:        []FlowKeyField{FlowKeyProto, FlowKeySrcIP, FlowKeyDstIP, FlowKeySrcPort, FlowKeyDstPort},
+		SrcPrefixLen:  32,
+		DstPrefixLen:  32,
+		SrcPrefixLen6: 128,
+		DstPrefixLen6: 128,
+	}
+}
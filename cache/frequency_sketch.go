@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// countMinSketch estimates counts (e.g. access frequency) of FiveTuples, counters saturate at MaxCount
+type countMinSketch struct {
+	Width, Depth uint
+	MaxCount     uint32
+
+	counters [][]uint32 // len(counters) == Depth, each len == Width
+}
+
+func newCountMinSketch(width, depth uint, maxCount uint32) *countMinSketch {
+	if width == 0 || depth == 0 {
+		panic(fmt.Sprintf("Count-Min sketch needs positive width and depth, got: %d x %d", width, depth))
+	}
+
+	counters := make([][]uint32, depth)
+	for i := range counters {
+		counters[i] = make([]uint32, width)
+	}
+
+	return &countMinSketch{
+		Width:    width,
+		Depth:    depth,
+		MaxCount: maxCount,
+		counters: counters,
+	}
+}
+
+func (s *countMinSketch) add(f *FiveTuple, n uint32) {
+	for row := uint(0); row < s.Depth; row++ {
+		counter := &s.counters[row][uint(seededHash(f, uint32(row)))%s.Width]
+
+		if s.MaxCount-*counter < n {
+			*counter = s.MaxCount
+		} else {
+			*counter += n
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(f *FiveTuple) uint {
+	min := uint(s.MaxCount)
+
+	for row := uint(0); row < s.Depth; row++ {
+		if counter := uint(s.counters[row][uint(seededHash(f, uint32(row)))%s.Width]); counter < min {
+			min = counter
+		}
+	}
+
+	return min
+}
+
+func (s *countMinSketch) halve() {
+	for row := range s.counters {
+		for i := range s.counters[row] {
+			s.counters[row][i] >>= 1
+		}
+	}
+}
+
+// bloomFilter is used as doorkeeper of TinyLFU, FiveTuples accessed once are kept only in it
+type bloomFilter struct {
+	Size uint // number of bits
+
+	bits []uint64
+}
+
+const (
+	bloomFilterHashes     = 3
+	bloomFilterSeedOffset = 1 << 16 // separates hash functions from ones of countMinSketch
+)
+
+func newBloomFilter(size uint) *bloomFilter {
+	if size == 0 {
+		panic("Bloom filter needs positive size")
+	}
+
+	return &bloomFilter{
+		Size: size,
+		bits: make([]uint64, (size+63)/64),
+	}
+}
+
+func (b *bloomFilter) bitIdx(f *FiveTuple, i uint) uint {
+	return uint(seededHash(f, uint32(bloomFilterSeedOffset+i))) % b.Size
+}
+
+func (b *bloomFilter) contains(f *FiveTuple) bool {
+	for i := uint(0); i < bloomFilterHashes; i++ {
+		idx := b.bitIdx(f, i)
+
+		if b.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// add returns whether f was (probably) contained before
+func (b *bloomFilter) add(f *FiveTuple) bool {
+	contained := true
+
+	for i := uint(0); i < bloomFilterHashes; i++ {
+		idx := b.bitIdx(f, i)
+
+		if b.bits[idx/64]&(1<<(idx%64)) == 0 {
+			contained = false
+			b.bits[idx/64] |= 1 << (idx % 64)
+		}
+	}
+
+	return contained
+}
+
+func (b *bloomFilter) clear() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+}
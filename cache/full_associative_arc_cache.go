@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+)
+
+// Adaptive Replacement Cache (Megiddo and Modha, FAST '03)
+//
+// T1: entries seen only once recently, T2: entries seen at least twice recently
+// B1, B2: ghost entries (keys only) recently evicted from T1, T2
+// Target: adaptive target size of T1 (called p in the paper)
+type FullAssociativeARCCache struct {
+	Entries map[FiveTuple]*list.Element // entries in T1 or T2
+	Ghosts  map[FiveTuple]*list.Element // entries in B1 or B2
+	Size    uint
+	Target  float64
+
+	GhostHitB1 uint
+	GhostHitB2 uint
+
+	t1, t2, b1, b2 *list.List
+}
+
+type arcList int
+
+const (
+	arcT1 arcList = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+type fullAssociativeARCCacheEntry struct {
+	Refered   int
+	FiveTuple FiveTuple
+	List      arcList
+}
+
+func (cache *FullAssociativeARCCache) StatString() string {
+	return fmt.Sprintf("{\"T1\": %d, \"T2\": %d, \"B1\": %d, \"B2\": %d, \"Target\": %v, \"GhostHitB1\": %d, \"GhostHitB2\": %d}",
+		cache.t1.Len(), cache.t2.Len(), cache.b1.Len(), cache.b2.Len(), cache.Target, cache.GhostHitB1, cache.GhostHitB2)
+}
+
+func (cache *FullAssociativeARCCache) ResetStat() {
+	cache.GhostHitB1 = 0
+	cache.GhostHitB2 = 0
+}
+
+func (cache *FullAssociativeARCCache) AssertImmutableCondition() {
+	if int(cache.Size) < cache.t1.Len()+cache.t2.Len() {
+		panic(fmt.Sprintln("len(T1) + len(T2):", cache.t1.Len()+cache.t2.Len(), ", expected: less than or equal to", cache.Size))
+	}
+
+	if int(cache.Size) < cache.t1.Len()+cache.b1.Len() {
+		panic(fmt.Sprintln("len(T1) + len(B1):", cache.t1.Len()+cache.b1.Len(), ", expected: less than or equal to", cache.Size))
+	}
+
+	if int(2*cache.Size) < cache.t1.Len()+cache.t2.Len()+cache.b1.Len()+cache.b2.Len() {
+		panic(fmt.Sprintln("len(T1) + len(T2) + len(B1) + len(B2):", cache.t1.Len()+cache.t2.Len()+cache.b1.Len()+cache.b2.Len(), ", expected: less than or equal to", 2*cache.Size))
+	}
+
+	if len(cache.Entries) != cache.t1.Len()+cache.t2.Len() {
+		panic(fmt.Sprintln("len(cache.Entries):", len(cache.Entries), ", expected: ", cache.t1.Len()+cache.t2.Len()))
+	}
+
+	if len(cache.Ghosts) != cache.b1.Len()+cache.b2.Len() {
+		panic(fmt.Sprintln("len(cache.Ghosts):", len(cache.Ghosts), ", expected: ", cache.b1.Len()+cache.b2.Len()))
+	}
+}
+
+func (cache *FullAssociativeARCCache) listOf(l arcList) *list.List {
+	switch l {
+	case arcT1:
+		return cache.t1
+	case arcT2:
+		return cache.t2
+	case arcB1:
+		return cache.b1
+	case arcB2:
+		return cache.b2
+	default:
+		panic(fmt.Sprintf("Unknown arcList value: %d", l))
+	}
+}
+
+// moveToFront moves elem to the MRU position of list `to`, and returns new element
+func (cache *FullAssociativeARCCache) moveToFront(elem *list.Element, to arcList) *list.Element {
+	entry := elem.Value.(fullAssociativeARCCacheEntry)
+	cache.listOf(entry.List).Remove(elem)
+
+	switch entry.List {
+	case arcT1, arcT2:
+		delete(cache.Entries, entry.FiveTuple)
+	case arcB1, arcB2:
+		delete(cache.Ghosts, entry.FiveTuple)
+	}
+
+	entry.List = to
+	newElem := cache.listOf(to).PushFront(entry)
+
+	switch to {
+	case arcT1, arcT2:
+		cache.Entries[entry.FiveTuple] = newElem
+	case arcB1, arcB2:
+		cache.Ghosts[entry.FiveTuple] = newElem
+	}
+
+	return newElem
+}
+
+// removeLRU removes LRU entry of ghost list `from` (B1 or B2)
+func (cache *FullAssociativeARCCache) removeLRU(from arcList) {
+	l := cache.listOf(from)
+	entry := l.Remove(l.Back()).(fullAssociativeARCCacheEntry)
+	delete(cache.Ghosts, entry.FiveTuple)
+}
+
+// replacesT1 returns whether REPLACE evicts LRU entry of T1 (otherwise of T2) with the target
+func (cache *FullAssociativeARCCache) replacesT1(target float64, inB2 bool) bool {
+	t1Len := float64(cache.t1.Len())
+	return cache.t1.Len() != 0 && (target < t1Len || (inB2 && t1Len == target) || cache.t2.Len() == 0)
+}
+
+// replace evicts LRU entry of T1 or T2 into B1 or B2 (REPLACE in the paper)
+func (cache *FullAssociativeARCCache) replace(inB2 bool) *FiveTuple {
+	var evictedElem *list.Element
+	if cache.replacesT1(cache.Target, inB2) {
+		evictedElem = cache.moveToFront(cache.t1.Back(), arcB1)
+	} else {
+		evictedElem = cache.moveToFront(cache.t2.Back(), arcB2)
+	}
+
+	evictedFiveTuple := evictedElem.Value.(fullAssociativeARCCacheEntry).FiveTuple
+	return &evictedFiveTuple
+}
+
+func (cache *FullAssociativeARCCache) IsCached(p *Packet, update bool) (bool, *int) {
+	return cache.IsCachedWithFiveTuple(p.FiveTuple(), update)
+}
+
+func (cache *FullAssociativeARCCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	hitElem, hit := cache.Entries[*f]
+
+	cache.AssertImmutableCondition()
+
+	if hit && update {
+		// Case I: move to MRU of T2
+		hitEntry := hitElem.Value.(fullAssociativeARCCacheEntry)
+		hitElem.Value = fullAssociativeARCCacheEntry{
+			Refered:   hitEntry.Refered + 1,
+			FiveTuple: hitEntry.FiveTuple,
+			List:      hitEntry.List,
+		}
+
+		cache.moveToFront(hitElem, arcT2)
+	}
+
+	cache.AssertImmutableCondition()
+
+	return hit, nil
+}
+
+func (cache *FullAssociativeARCCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	cache.AssertImmutableCondition()
+
+	evictedFiveTuples := []*FiveTuple{}
+
+	if hit, _ := cache.IsCachedWithFiveTuple(f, true); hit {
+		return evictedFiveTuples
+	}
+
+	size := float64(cache.Size)
+	full := int(cache.Size) <= cache.t1.Len()+cache.t2.Len()
+
+	if ghostElem, ghostHit := cache.Ghosts[*f]; ghostHit {
+		inB2 := ghostElem.Value.(fullAssociativeARCCacheEntry).List == arcB2
+		b1Len, b2Len := float64(cache.b1.Len()), float64(cache.b2.Len())
+
+		if inB2 {
+			// Case III: shrink target of T1
+			cache.GhostHitB2 += 1
+			cache.Target = math.Max(cache.Target-math.Max(b1Len/b2Len, 1), 0)
+		} else {
+			// Case II: grow target of T1
+			cache.GhostHitB1 += 1
+			cache.Target = math.Min(cache.Target+math.Max(b2Len/b1Len, 1), size)
+		}
+
+		if full {
+			evictedFiveTuples = append(evictedFiveTuples, cache.replace(inB2))
+		}
+
+		cache.moveToFront(ghostElem, arcT2)
+	} else {
+		// Case IV: completely new entry
+		l1Len := cache.t1.Len() + cache.b1.Len()
+		totalLen := l1Len + cache.t2.Len() + cache.b2.Len()
+
+		if l1Len == int(cache.Size) {
+			if cache.t1.Len() < int(cache.Size) {
+				cache.removeLRU(arcB1)
+				if full {
+					evictedFiveTuples = append(evictedFiveTuples, cache.replace(false))
+				}
+			} else {
+				// T1 occupies whole cache, evict LRU of T1 without remembering it
+				replacedEntry := cache.t1.Remove(cache.t1.Back()).(fullAssociativeARCCacheEntry)
+				delete(cache.Entries, replacedEntry.FiveTuple)
+				evictedFiveTuples = append(evictedFiveTuples, &replacedEntry.FiveTuple)
+			}
+		} else if int(cache.Size) <= totalLen {
+			if totalLen == int(2*cache.Size) {
+				cache.removeLRU(arcB2)
+			}
+			if full {
+				evictedFiveTuples = append(evictedFiveTuples, cache.replace(false))
+			}
+		}
+
+		newEntry := fullAssociativeARCCacheEntry{
+			FiveTuple: *f,
+			List:      arcT1,
+		}
+
+		cache.Entries[*f] = cache.t1.PushFront(newEntry)
+	}
+
+	cache.AssertImmutableCondition()
+
+	return evictedFiveTuples
+}
+
+// PeekVictim returns the entry CacheFiveTuple would evict, following the cases of CacheFiveTuple without changing state
+func (cache *FullAssociativeARCCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	if int(cache.Size) > cache.t1.Len()+cache.t2.Len() {
+		// not full, nothing is evicted
+		return nil, false
+	}
+
+	target := cache.Target
+	inB2 := false
+
+	if ghostElem, ghostHit := cache.Ghosts[*f]; ghostHit {
+		// Case II, III: the target is adapted before REPLACE
+		inB2 = ghostElem.Value.(fullAssociativeARCCacheEntry).List == arcB2
+		b1Len, b2Len := float64(cache.b1.Len()), float64(cache.b2.Len())
+
+		if inB2 {
+			target = math.Max(target-math.Max(b1Len/b2Len, 1), 0)
+		} else {
+			target = math.Min(target+math.Max(b2Len/b1Len, 1), float64(cache.Size))
+		}
+	} else if cache.t1.Len() == int(cache.Size) {
+		// Case IV: T1 occupies whole cache
+		victim := cache.t1.Back().Value.(fullAssociativeARCCacheEntry).FiveTuple
+		return &victim, true
+	}
+
+	victimList := cache.t2
+	if cache.replacesT1(target, inB2) {
+		victimList = cache.t1
+	}
+
+	victim := victimList.Back().Value.(fullAssociativeARCCacheEntry).FiveTuple
+	return &victim, true
+}
+
+func (cache *FullAssociativeARCCache) InvalidateFiveTuple(f *FiveTuple) {
+	hitElem, hit := cache.Entries[*f]
+
+	if !hit {
+		return
+	}
+
+	cache.listOf(hitElem.Value.(fullAssociativeARCCacheEntry).List).Remove(hitElem)
+	delete(cache.Entries, *f)
+
+	cache.AssertImmutableCondition()
+}
+
+func (cache *FullAssociativeARCCache) Clear() {
+	cache.Entries = map[FiveTuple]*list.Element{}
+	cache.Ghosts = map[FiveTuple]*list.Element{}
+	cache.Target = 0
+	cache.t1.Init()
+	cache.t2.Init()
+	cache.b1.Init()
+	cache.b2.Init()
+}
+
+func (cache *FullAssociativeARCCache) Description() string {
+	return "FullAssociativeARCCache"
+}
+
+func (cache *FullAssociativeARCCache) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d}", cache.Description(), cache.Size)
+}
+
+func NewFullAssociativeARCCache(size uint) *FullAssociativeARCCache {
+	return &FullAssociativeARCCache{
+		Entries: map[FiveTuple]*list.Element{},
+		Ghosts:  map[FiveTuple]*list.Element{},
+		Size:    size,
+		t1:      list.New(),
+		t2:      list.New(),
+		b1:      list.New(),
+		b2:      list.New(),
+	}
+}
@@ -15,6 +15,7 @@ type FullAssociativeFIFOCache struct {
 type fullAssociativeFIFOCacheEntry struct {
 	Refered   int
 	FiveTuple FiveTuple
+	Used      bool // false for empty entries, FiveTuple{} may be a valid key
 }
 
 func (cache *FullAssociativeFIFOCache) StatString() string {
@@ -48,6 +49,7 @@ func (cache *FullAssociativeFIFOCache) IsCachedWithFiveTuple(f *FiveTuple, updat
 		hitElem.Value = fullAssociativeFIFOCacheEntry{
 			Refered:   hitEntry.Refered + 1,
 			FiveTuple: hitEntry.FiveTuple,
+			Used:      true,
 		}
 	}
 
@@ -68,10 +70,13 @@ func (cache *FullAssociativeFIFOCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple
 	oldestElem := cache.evictList.Back()
 
 	replacedEntry := cache.evictList.Remove(oldestElem).(fullAssociativeFIFOCacheEntry)
-	delete(cache.Entries, replacedEntry.FiveTuple)
+	if replacedEntry.Used {
+		delete(cache.Entries, replacedEntry.FiveTuple)
+	}
 
 	newEntry := fullAssociativeFIFOCacheEntry{
 		FiveTuple: *f,
+		Used:      true,
 	}
 
 	newElem := cache.evictList.PushFront(newEntry)
@@ -79,7 +84,7 @@ func (cache *FullAssociativeFIFOCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple
 
 	cache.AssertImmutableCondition()
 
-	if replacedEntry.FiveTuple == (FiveTuple{}) {
+	if !replacedEntry.Used {
 		return evictedFiveTuples
 	}
 
@@ -88,11 +93,25 @@ func (cache *FullAssociativeFIFOCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple
 	return evictedFiveTuples
 }
 
+func (cache *FullAssociativeFIFOCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	victimEntry := cache.evictList.Back().Value.(fullAssociativeFIFOCacheEntry)
+	if !victimEntry.Used {
+		return nil, false
+	}
+
+	victim := victimEntry.FiveTuple
+	return &victim, true
+}
+
 func (cache *FullAssociativeFIFOCache) InvalidateFiveTuple(f *FiveTuple) {
 	hitElem, hit := cache.Entries[*f]
 
 	if !hit {
-		panic("entry not cached")
+		return
 	}
 
 	cache.evictList.Remove(hitElem)
@@ -104,7 +123,12 @@ func (cache *FullAssociativeFIFOCache) InvalidateFiveTuple(f *FiveTuple) {
 }
 
 func (cache *FullAssociativeFIFOCache) Clear() {
-	panic("Not implemented")
+	cache.Entries = map[FiveTuple]*list.Element{}
+	cache.evictList.Init()
+
+	for i := 0; i < int(cache.Size); i++ {
+		cache.evictList.PushBack(fullAssociativeFIFOCacheEntry{})
+	}
 }
 
 func (cache *FullAssociativeFIFOCache) Description() string {
@@ -5,30 +5,122 @@ import (
 	"fmt"
 )
 
+// LFU cache with O(1) operations by frequency buckets (Shah et al., "An O(1) algorithm for implementing the LFU cache eviction scheme").
+// In each bucket, the entry which moved into the bucket most recently is at the front,
+// and the entry at the back of the least frequently used bucket is evicted.
 type FullAssociativeLFUCache struct {
-	Entries map[FiveTuple]*list.Element
+	Entries map[FiveTuple]*list.Element // element of entries of a bucket
 	Size    uint
+	Aging   *LFUAging // nil if counters are not aged
 
-	evictList *list.List
+	buckets *list.List // of *lfuBucket in ascending order of Refered, no empty bucket
+}
+
+type lfuBucket struct {
+	Refered int
+	entries *list.List // of *fullAssociativeLFUCacheEntry
 }
 
 type fullAssociativeLFUCacheEntry struct {
-	Refered   int
 	FiveTuple FiveTuple
+	bucket    *list.Element // element of FullAssociativeLFUCache.buckets
+}
+
+// LFUAging halves reference counts of LFU caches every Accesses lookups or every Seconds of packet time,
+// so that flows which were heavy only in the past can be evicted. 0 disables each condition.
+type LFUAging struct {
+	Accesses uint
+	Seconds  float64
+	Agings   uint // number of times counters are halved
+
+	accesses uint
+	nextTime float64
+	started  bool
+}
+
+func NewLFUAging(accesses uint, seconds float64) *LFUAging {
+	return &LFUAging{
+		Accesses: accesses,
+		Seconds:  seconds,
+	}
+}
+
+// access counts a lookup, and returns number of times counters should be halved
+func (a *LFUAging) access() uint {
+	if a.Accesses == 0 {
+		return 0
+	}
+
+	a.accesses += 1
+
+	if a.accesses < a.Accesses {
+		return 0
+	}
+
+	a.accesses = 0
+	a.Agings += 1
+
+	return 1
+}
+
+// observe advances time to the packet, and returns number of times counters should be halved
+func (a *LFUAging) observe(time float64) uint {
+	if a.Seconds == 0 {
+		return 0
+	}
+
+	if !a.started {
+		a.started = true
+		a.nextTime = time + a.Seconds
+		return 0
+	}
+
+	if time < a.nextTime {
+		return 0
+	}
+
+	// counters are halved for each period elapsed
+	periods := uint((time-a.nextTime)/a.Seconds) + 1
+	a.nextTime += float64(periods) * a.Seconds
+	a.Agings += periods
+
+	return periods
+}
+
+func (a *LFUAging) ParameterString() string {
+	if a == nil {
+		return "null"
+	}
+
+	return fmt.Sprintf("{\"Accesses\": %d, \"Seconds\": %v}", a.Accesses, a.Seconds)
 }
 
 func (cache *FullAssociativeLFUCache) StatString() string {
-	return ""
+	if cache.Aging == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("{\"Agings\": %d}", cache.Aging.Agings)
+}
+
+func (cache *FullAssociativeLFUCache) ResetStat() {
+	if cache.Aging != nil {
+		cache.Aging.Agings = 0
+	}
 }
 
 func (cache *FullAssociativeLFUCache) AssertImmutableCondition() {
 	if int(cache.Size) < len(cache.Entries) {
 		panic(fmt.Sprintln("len(cache.Entries):", len(cache.Entries), ", expected: less than or equal to", cache.Size))
 	}
+}
 
-	if cache.evictList.Len() != int(cache.Size) {
-		panic(fmt.Sprintln("cache.evictList.Len():", cache.evictList.Len(), ", expected: ", cache.Size))
+func (cache *FullAssociativeLFUCache) ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple {
+	if cache.Aging != nil {
+		cache.age(cache.Aging.observe(p.Time))
 	}
+
+	return []*FiveTuple{}
 }
 
 func (cache *FullAssociativeLFUCache) IsCached(p *Packet, update bool) (bool, *int) {
@@ -36,101 +128,141 @@ func (cache *FullAssociativeLFUCache) IsCached(p *Packet, update bool) (bool, *i
 }
 
 func (cache *FullAssociativeLFUCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
-	hitElem, hit := cache.Entries[*f]
+	if update && cache.Aging != nil {
+		cache.age(cache.Aging.access())
+	}
 
-	cache.AssertImmutableCondition()
+	hitElem, hit := cache.Entries[*f]
 
 	if hit && update {
-		// update refered count
-		hitEntry := hitElem.Value.(fullAssociativeLFUCacheEntry)
-		hitElem.Value = fullAssociativeLFUCacheEntry{
-			Refered:   hitEntry.Refered + 1,
-			FiveTuple: hitEntry.FiveTuple,
-		}
+		cache.refer(hitElem)
+	}
 
-		fRefered := hitElem.Value.(fullAssociativeLFUCacheEntry).Refered
-		el := hitElem.Prev()
+	return hit, nil
+}
 
-		// find elem that is more frequently used than hitElem
-		for el != nil && el.Value.(fullAssociativeLFUCacheEntry).Refered <= fRefered {
-			el = el.Prev()
-		}
+// refer moves the entry to the front of the bucket of the next reference count
+func (cache *FullAssociativeLFUCache) refer(elem *list.Element) {
+	entry := elem.Value.(*fullAssociativeLFUCacheEntry)
+	bucketElem := entry.bucket
+	bucket := bucketElem.Value.(*lfuBucket)
 
-		if el == nil {
-			cache.evictList.MoveToFront(hitElem)
-		} else {
-			cache.evictList.MoveAfter(hitElem, el)
-		}
+	nextElem := bucketElem.Next()
+	if nextElem == nil || nextElem.Value.(*lfuBucket).Refered != bucket.Refered+1 {
+		nextElem = cache.buckets.InsertAfter(&lfuBucket{Refered: bucket.Refered + 1, entries: list.New()}, bucketElem)
 	}
 
-	cache.AssertImmutableCondition()
+	bucket.entries.Remove(elem)
+	if bucket.entries.Len() == 0 {
+		cache.buckets.Remove(bucketElem)
+	}
 
-	return hit, nil
+	entry.bucket = nextElem
+	cache.Entries[entry.FiveTuple] = nextElem.Value.(*lfuBucket).entries.PushFront(entry)
 }
 
-func (cache *FullAssociativeLFUCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
-	cache.AssertImmutableCondition()
-
-	evictedFiveTuples := []*FiveTuple{}
+// remove removes the entry from its bucket and Entries
+func (cache *FullAssociativeLFUCache) remove(elem *list.Element) *fullAssociativeLFUCacheEntry {
+	entry := elem.Value.(*fullAssociativeLFUCacheEntry)
+	bucket := entry.bucket.Value.(*lfuBucket)
 
-	if hit, _ := cache.IsCachedWithFiveTuple(f, true); hit {
-		return evictedFiveTuples
+	bucket.entries.Remove(elem)
+	if bucket.entries.Len() == 0 {
+		cache.buckets.Remove(entry.bucket)
 	}
 
-	lfuElem := cache.evictList.Back()
+	delete(cache.Entries, entry.FiveTuple)
 
-	replacedEntry := cache.evictList.Remove(lfuElem).(fullAssociativeLFUCacheEntry)
-	delete(cache.Entries, replacedEntry.FiveTuple)
+	return entry
+}
 
-	newEntry := fullAssociativeLFUCacheEntry{
-		FiveTuple: *f,
+// age halves reference counts shift times, entries merged into a bucket keep the order of their counts
+func (cache *FullAssociativeLFUCache) age(shift uint) {
+	if shift == 0 {
+		return
 	}
 
-	oldLFUel := cache.evictList.Back()
+	buckets := list.New()
 
-	newElem := cache.evictList.PushBack(newEntry)
+	for bucketElem := cache.buckets.Back(); bucketElem != nil; bucketElem = bucketElem.Prev() {
+		bucket := bucketElem.Value.(*lfuBucket)
+		refered := bucket.Refered >> shift
 
-	// move newElem to successor of an elem that is refered at least 1 time.
-	for oldLFUel != nil && oldLFUel.Value.(fullAssociativeLFUCacheEntry).Refered == 0 {
-		oldLFUel = oldLFUel.Prev()
-	}
+		newBucketElem := buckets.Front()
+		if newBucketElem == nil || newBucketElem.Value.(*lfuBucket).Refered != refered {
+			newBucketElem = buckets.PushFront(&lfuBucket{Refered: refered, entries: list.New()})
+		}
+
+		newBucket := newBucketElem.Value.(*lfuBucket)
 
-	if oldLFUel == nil {
-		cache.evictList.MoveToFront(newElem)
-	} else {
-		cache.evictList.MoveAfter(newElem, oldLFUel)
+		for el := bucket.entries.Front(); el != nil; el = el.Next() {
+			entry := el.Value.(*fullAssociativeLFUCacheEntry)
+			entry.bucket = newBucketElem
+			cache.Entries[entry.FiveTuple] = newBucket.entries.PushBack(entry)
+		}
 	}
 
-	cache.Entries[*f] = newElem
+	cache.buckets = buckets
+}
 
+func (cache *FullAssociativeLFUCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
 	cache.AssertImmutableCondition()
 
-	if replacedEntry.FiveTuple == (FiveTuple{}) {
+	evictedFiveTuples := []*FiveTuple{}
+
+	if hitElem, hit := cache.Entries[*f]; hit {
+		cache.refer(hitElem)
 		return evictedFiveTuples
 	}
 
-	evictedFiveTuples = append(evictedFiveTuples, &replacedEntry.FiveTuple)
+	if len(cache.Entries) == int(cache.Size) {
+		lfuBucket := cache.buckets.Front().Value.(*lfuBucket)
+		replacedEntry := cache.remove(lfuBucket.entries.Back())
+		evictedFiveTuples = append(evictedFiveTuples, &replacedEntry.FiveTuple)
+	}
+
+	// new entry goes to the front of the bucket of 0 reference
+	bucketElem := cache.buckets.Front()
+	if bucketElem == nil || bucketElem.Value.(*lfuBucket).Refered != 0 {
+		bucketElem = cache.buckets.PushFront(&lfuBucket{Refered: 0, entries: list.New()})
+	}
+
+	newEntry := &fullAssociativeLFUCacheEntry{
+		FiveTuple: *f,
+		bucket:    bucketElem,
+	}
+	cache.Entries[*f] = bucketElem.Value.(*lfuBucket).entries.PushFront(newEntry)
+
+	cache.AssertImmutableCondition()
 
 	return evictedFiveTuples
 }
 
+func (cache *FullAssociativeLFUCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit || len(cache.Entries) < int(cache.Size) {
+		return nil, false
+	}
+
+	victim := cache.buckets.Front().Value.(*lfuBucket).entries.Back().Value.(*fullAssociativeLFUCacheEntry).FiveTuple
+
+	return &victim, true
+}
+
 func (cache *FullAssociativeLFUCache) InvalidateFiveTuple(f *FiveTuple) {
 	hitElem, hit := cache.Entries[*f]
 
 	if !hit {
-		panic("entry not cached")
+		return
 	}
 
-	cache.evictList.Remove(hitElem)
-	delete(cache.Entries, *f)
-
-	cache.evictList.PushBack(fullAssociativeLFUCacheEntry{})
+	cache.remove(hitElem)
 
 	cache.AssertImmutableCondition()
 }
 
 func (cache *FullAssociativeLFUCache) Clear() {
-	panic("Not implemented")
+	cache.Entries = map[FiveTuple]*list.Element{}
+	cache.buckets.Init()
 }
 
 func (cache *FullAssociativeLFUCache) Description() string {
@@ -138,19 +270,13 @@ func (cache *FullAssociativeLFUCache) Description() string {
 }
 
 func (cache *FullAssociativeLFUCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d}", cache.Description(), cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d, \"Aging\": %s}", cache.Description(), cache.Size, cache.Aging.ParameterString())
 }
 
 func NewFullAssociativeLFUCache(size uint) *FullAssociativeLFUCache {
-	evictList := list.New()
-
-	for i := 0; i < int(size); i++ {
-		evictList.PushBack(fullAssociativeLFUCacheEntry{})
-	}
-
 	return &FullAssociativeLFUCache{
-		Entries:   map[FiveTuple]*list.Element{},
-		Size:      size,
-		evictList: evictList,
+		Entries: map[FiveTuple]*list.Element{},
+		Size:    size,
+		buckets: list.New(),
 	}
 }
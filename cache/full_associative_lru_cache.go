@@ -15,6 +15,7 @@ type FullAssociativeLRUCache struct {
 type fullAssociativeLRUCacheEntry struct {
 	Refered   int
 	FiveTuple FiveTuple
+	Used      bool // false for empty entries, FiveTuple{} may be a valid key
 }
 
 func (cache *FullAssociativeLRUCache) StatString() string {
@@ -48,6 +49,7 @@ func (cache *FullAssociativeLRUCache) IsCachedWithFiveTuple(f *FiveTuple, update
 		hitElem.Value = fullAssociativeLRUCacheEntry{
 			Refered:   hitEntry.Refered + 1,
 			FiveTuple: hitEntry.FiveTuple,
+			Used:      true,
 		}
 	}
 
@@ -68,10 +70,13 @@ func (cache *FullAssociativeLRUCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple
 	oldestElem := cache.evictList.Back()
 
 	replacedEntry := cache.evictList.Remove(oldestElem).(fullAssociativeLRUCacheEntry)
-	delete(cache.Entries, replacedEntry.FiveTuple)
+	if replacedEntry.Used {
+		delete(cache.Entries, replacedEntry.FiveTuple)
+	}
 
 	newEntry := fullAssociativeLRUCacheEntry{
 		FiveTuple: *f,
+		Used:      true,
 	}
 
 	newElem := cache.evictList.PushFront(newEntry)
@@ -79,7 +84,7 @@ func (cache *FullAssociativeLRUCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple
 
 	cache.AssertImmutableCondition()
 
-	if replacedEntry.FiveTuple == (FiveTuple{}) {
+	if !replacedEntry.Used {
 		return evictedFiveTuples
 	}
 
@@ -88,11 +93,25 @@ func (cache *FullAssociativeLRUCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple
 	return evictedFiveTuples
 }
 
+func (cache *FullAssociativeLRUCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	victimEntry := cache.evictList.Back().Value.(fullAssociativeLRUCacheEntry)
+	if !victimEntry.Used {
+		return nil, false
+	}
+
+	victim := victimEntry.FiveTuple
+	return &victim, true
+}
+
 func (cache *FullAssociativeLRUCache) InvalidateFiveTuple(f *FiveTuple) {
 	hitElem, hit := cache.Entries[*f]
 
 	if !hit {
-		panic("entry not cached")
+		return
 	}
 
 	cache.evictList.Remove(hitElem)
@@ -104,7 +123,12 @@ func (cache *FullAssociativeLRUCache) InvalidateFiveTuple(f *FiveTuple) {
 }
 
 func (cache *FullAssociativeLRUCache) Clear() {
-	panic("Not implemented")
+	cache.Entries = map[FiveTuple]*list.Element{}
+	cache.evictList.Init()
+
+	for i := 0; i < int(cache.Size); i++ {
+		cache.evictList.PushBack(fullAssociativeLRUCacheEntry{})
+	}
 }
 
 func (cache *FullAssociativeLRUCache) Description() string {
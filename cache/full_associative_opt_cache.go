@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// Belady's OPT (MIN): evicts the entry whose next reference is farthest in the future.
+// Needs OPTOracle built by a pre-pass over the trace, so it gives an upper bound of hit rate.
+type FullAssociativeOPTCache struct {
+	Entries map[FiveTuple]*fullAssociativeOPTCacheEntry
+	Size    uint
+	Oracle  *OPTOracle
+
+	evictHeap fullAssociativeOPTCacheHeap
+}
+
+type fullAssociativeOPTCacheEntry struct {
+	Refered   int
+	FiveTuple FiveTuple
+	NextUse   int
+	heapIdx   int
+}
+
+// max-heap of NextUse
+type fullAssociativeOPTCacheHeap []*fullAssociativeOPTCacheEntry
+
+func (h fullAssociativeOPTCacheHeap) Len() int           { return len(h) }
+func (h fullAssociativeOPTCacheHeap) Less(i, j int) bool { return h[i].NextUse > h[j].NextUse }
+func (h fullAssociativeOPTCacheHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIdx = i
+	h[j].heapIdx = j
+}
+
+func (h *fullAssociativeOPTCacheHeap) Push(x interface{}) {
+	entry := x.(*fullAssociativeOPTCacheEntry)
+	entry.heapIdx = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *fullAssociativeOPTCacheHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func (cache *FullAssociativeOPTCache) StatString() string {
+	return ""
+}
+
+func (cache *FullAssociativeOPTCache) AssertImmutableCondition() {
+	if int(cache.Size) < len(cache.Entries) {
+		panic(fmt.Sprintln("len(cache.Entries):", len(cache.Entries), ", expected: less than or equal to", cache.Size))
+	}
+
+	if cache.evictHeap.Len() != len(cache.Entries) {
+		panic(fmt.Sprintln("cache.evictHeap.Len():", cache.evictHeap.Len(), ", expected: ", len(cache.Entries)))
+	}
+}
+
+// called by OPTOracle.Advance, next reference of f has changed
+func (cache *FullAssociativeOPTCache) updateNextUse(f *FiveTuple) {
+	entry, hit := cache.Entries[*f]
+
+	if !hit {
+		return
+	}
+
+	entry.NextUse = cache.Oracle.NextUse(f)
+	heap.Fix(&cache.evictHeap, entry.heapIdx)
+}
+
+func (cache *FullAssociativeOPTCache) IsCached(p *Packet, update bool) (bool, *int) {
+	return cache.IsCachedWithFiveTuple(p.FiveTuple(), update)
+}
+
+func (cache *FullAssociativeOPTCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	hitEntry, hit := cache.Entries[*f]
+
+	if hit && update {
+		hitEntry.Refered += 1
+	}
+
+	return hit, nil
+}
+
+func (cache *FullAssociativeOPTCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	cache.AssertImmutableCondition()
+
+	evictedFiveTuples := []*FiveTuple{}
+
+	if hit, _ := cache.IsCachedWithFiveTuple(f, true); hit {
+		return evictedFiveTuples
+	}
+
+	if len(cache.Entries) == int(cache.Size) {
+		replacedEntry := heap.Pop(&cache.evictHeap).(*fullAssociativeOPTCacheEntry)
+		delete(cache.Entries, replacedEntry.FiveTuple)
+
+		evictedFiveTuples = append(evictedFiveTuples, &replacedEntry.FiveTuple)
+	}
+
+	newEntry := &fullAssociativeOPTCacheEntry{
+		FiveTuple: *f,
+		NextUse:   cache.Oracle.NextUse(f),
+	}
+
+	heap.Push(&cache.evictHeap, newEntry)
+	cache.Entries[*f] = newEntry
+
+	cache.AssertImmutableCondition()
+
+	return evictedFiveTuples
+}
+
+func (cache *FullAssociativeOPTCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	if len(cache.Entries) < int(cache.Size) {
+		return nil, false
+	}
+
+	victim := cache.evictHeap[0].FiveTuple
+	return &victim, true
+}
+
+func (cache *FullAssociativeOPTCache) InvalidateFiveTuple(f *FiveTuple) {
+	hitEntry, hit := cache.Entries[*f]
+
+	if !hit {
+		return
+	}
+
+	heap.Remove(&cache.evictHeap, hitEntry.heapIdx)
+	delete(cache.Entries, *f)
+
+	cache.AssertImmutableCondition()
+}
+
+func (cache *FullAssociativeOPTCache) Clear() {
+	cache.Entries = map[FiveTuple]*fullAssociativeOPTCacheEntry{}
+	cache.evictHeap = fullAssociativeOPTCacheHeap{}
+}
+
+func (cache *FullAssociativeOPTCache) Description() string {
+	return "FullAssociativeOPTCache"
+}
+
+func (cache *FullAssociativeOPTCache) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d}", cache.Description(), cache.Size)
+}
+
+func NewFullAssociativeOPTCache(size uint, oracle *OPTOracle) *FullAssociativeOPTCache {
+	cache := &FullAssociativeOPTCache{
+		Entries:   map[FiveTuple]*fullAssociativeOPTCacheEntry{},
+		Size:      size,
+		Oracle:    oracle,
+		evictHeap: fullAssociativeOPTCacheHeap{},
+	}
+
+	oracle.register(cache)
+
+	return cache
+}
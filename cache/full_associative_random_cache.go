@@ -4,14 +4,16 @@ import (
 	"container/list"
 	"fmt"
 	"math/rand"
-	"time"
 )
 
 type FullAssociativeRandomCache struct {
 	Entries map[FiveTuple]*list.Element
 	Size    uint
+	Seed    int64 // seed of rng, results are reproducible with the same seed
 
-	evictList *list.List
+	evictList    *list.List
+	rng          *rand.Rand
+	nextEvictIdx int // index in evictList of the next eviction drawn by PeekVictim, -1 if not drawn
 }
 
 type fullAssociativeRandomCacheEntry struct {
@@ -19,10 +21,6 @@ type fullAssociativeRandomCacheEntry struct {
 	FiveTuple FiveTuple
 }
 
-func init () {
-	rand.Seed(time.Now().UnixNano())
-}
-
 func (cache *FullAssociativeRandomCache) StatString() string {
 	return ""
 }
@@ -72,7 +70,8 @@ func (cache *FullAssociativeRandomCache) CacheFiveTuple(f *FiveTuple) []*FiveTup
 	if len(cache.Entries) == int(cache.Size) {
 		// need to evict
 
-		evictIdx := rand.Intn(int(cache.Size))
+		evictIdx := cache.drawEvictIdx()
+		cache.nextEvictIdx = -1
 
 		el := cache.evictList.Front()
 
@@ -100,11 +99,39 @@ func (cache *FullAssociativeRandomCache) CacheFiveTuple(f *FiveTuple) []*FiveTup
 	return evictedFiveTuples
 }
 
+// drawEvictIdx returns index of the next eviction, drawn only once until the eviction
+func (cache *FullAssociativeRandomCache) drawEvictIdx() int {
+	if cache.nextEvictIdx < 0 {
+		cache.nextEvictIdx = cache.rng.Intn(int(cache.Size))
+	}
+
+	return cache.nextEvictIdx
+}
+
+// PeekVictim draws the next eviction in advance, CacheFiveTuple evicts the same entry
+func (cache *FullAssociativeRandomCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	if len(cache.Entries) < int(cache.Size) {
+		return nil, false
+	}
+
+	el := cache.evictList.Front()
+	for i := 0; i < cache.drawEvictIdx(); i++ {
+		el = el.Next()
+	}
+
+	victim := el.Value.(fullAssociativeRandomCacheEntry).FiveTuple
+	return &victim, true
+}
+
 func (cache *FullAssociativeRandomCache) InvalidateFiveTuple(f *FiveTuple) {
 	hitElem, hit := cache.Entries[*f]
 
 	if !hit {
-		panic("entry not cached")
+		return
 	}
 
 	cache.evictList.Remove(hitElem)
@@ -114,7 +141,8 @@ func (cache *FullAssociativeRandomCache) InvalidateFiveTuple(f *FiveTuple) {
 }
 
 func (cache *FullAssociativeRandomCache) Clear() {
-	panic("Not implemented")
+	cache.Entries = map[FiveTuple]*list.Element{}
+	cache.evictList.Init()
 }
 
 func (cache *FullAssociativeRandomCache) Description() string {
@@ -122,15 +150,23 @@ func (cache *FullAssociativeRandomCache) Description() string {
 }
 
 func (cache *FullAssociativeRandomCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d}", cache.Description(), cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d, \"Seed\": %d}", cache.Description(), cache.Size, cache.Seed)
+}
+
+func NewFullAssociativeRandomCache(size uint, seed int64) *FullAssociativeRandomCache {
+	return newFullAssociativeRandomCacheWithRand(size, seed, rand.New(rand.NewSource(seed)))
 }
 
-func NewFullAssociativeRandomCache(size uint) *FullAssociativeRandomCache {
+// rng may be shared between caches (e.g. sets of NWaySetAssociativeRandomCache)
+func newFullAssociativeRandomCacheWithRand(size uint, seed int64, rng *rand.Rand) *FullAssociativeRandomCache {
 	evictList := list.New()
 
 	return &FullAssociativeRandomCache{
-		Entries:   map[FiveTuple]*list.Element{},
-		Size:      size,
-		evictList: evictList,
+		Entries:      map[FiveTuple]*list.Element{},
+		Size:         size,
+		Seed:         seed,
+		evictList:    evictList,
+		rng:          rng,
+		nextEvictIdx: -1,
 	}
 }
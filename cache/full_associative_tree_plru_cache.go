@@ -8,6 +8,11 @@ type FullAssociativeTreePLRUCache struct {
 	Entries map[FiveTuple]uint
 	Size    uint
 
+	// if true, Size may not be power of two: the tree has leaves of the next power of two,
+	// and leaves with index Size or more are never chosen as victim
+	MaskedLeaves bool
+	treeSize     uint // number of leaves of the tree
+
 	// heap-like tree
 	// evictTree[0] == LSB. 2nd-least significant bit is evictTree[1] if zero, evictTree[2] if one
 	// evictTree[1] == ...
@@ -67,7 +72,7 @@ func (cache *FullAssociativeTreePLRUCache) IsCachedWithFiveTuple(f *FiveTuple, u
 		// log.Printf("$$$ IsCached: update: %+v\n", f)
 		// log.Printf("elemIdx: %v\n", hitElemIdx)
 		treeIdx := uint(0)
-		for mask := cache.Size >> 1; 0 < mask; mask >>= 1 {
+		for mask := cache.treeSize >> 1; 0 < mask; mask >>= 1 {
 			// (    (false) == 0 == 1) == true                     // => not flip
 			// (    (false)    0 == 1) == false                    // =>     flip
 			// (     (true)    1 == 1) == true                     // =>     flip
@@ -99,28 +104,28 @@ func (cache *FullAssociativeTreePLRUCache) CacheFiveTuple(f *FiveTuple) []*FiveT
 
 	elemIdx := uint(0)
 	treeIdx := 0
-	for bit := uint(0); 1 < (cache.Size >> bit); bit += 1 {
-		// (    (false) == 0 == 1) == true                     // => not flip
-		// (    (false)    0 == 1) == false                    // =>     flip
-		// (     (true)    1 == 1) == true                     // =>     flip
-		// (     (true)    1 == 1) == false                    // => not flip
-		if bit != 0 {
-			elemIdx <<= 1
+	for width := cache.treeSize >> 1; 0 < width; width >>= 1 {
+		// width: number of leaves under each child of the node
+		right := cache.evictTree[treeIdx]
+
+		if right && cache.Size <= (elemIdx<<1|1)*width {
+			// all leaves under the right child are masked
+			right = false
 		}
-		if cache.evictTree[treeIdx] {
+
+		elemIdx <<= 1
+		if right {
 			elemIdx |= 1
 		}
 
-		currentTreeIdx := treeIdx
-		// log.Printf("treeIdx: %v\n", treeIdx)
+		// point the other child of the victim
+		cache.evictTree[treeIdx] = !right
 
-		if !cache.evictTree[treeIdx] {
+		if !right {
 			treeIdx = (2*(treeIdx+1) + 0) - 1
 		} else {
 			treeIdx = (2*(treeIdx+1) + 1) - 1
 		}
-
-		cache.evictTree[currentTreeIdx] = !cache.evictTree[currentTreeIdx]
 	}
 
 	evictedFiveTuple, hit := cache.entryFromIdx[elemIdx]
@@ -142,18 +147,51 @@ func (cache *FullAssociativeTreePLRUCache) CacheFiveTuple(f *FiveTuple) []*FiveT
 	return evictedFiveTuples
 }
 
+// PeekVictim follows the tree as CacheFiveTuple does, without flipping nodes
+func (cache *FullAssociativeTreePLRUCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	elemIdx := uint(0)
+	treeIdx := 0
+	for width := cache.treeSize >> 1; 0 < width; width >>= 1 {
+		right := cache.evictTree[treeIdx]
+
+		if right && cache.Size <= (elemIdx<<1|1)*width {
+			right = false
+		}
+
+		elemIdx <<= 1
+		if !right {
+			treeIdx = (2*(treeIdx+1) + 0) - 1
+		} else {
+			elemIdx |= 1
+			treeIdx = (2*(treeIdx+1) + 1) - 1
+		}
+	}
+
+	victim, used := cache.entryFromIdx[elemIdx]
+	if !used {
+		return nil, false
+	}
+
+	victimCopy := *victim
+	return &victimCopy, true
+}
+
 func (cache *FullAssociativeTreePLRUCache) InvalidateFiveTuple(f *FiveTuple) {
 	hitElemIdx, hit := cache.Entries[*f]
 
 	// log.Printf("$$$ Invalidate: update: %+v (idx: %v)\n", f, hitElemIdx)
 
 	if !hit {
-		panic("entry not cached")
+		return
 	}
 
 	// mark hitElemIdx (idx of f as oldest)
 	treeIdx := uint(0)
-	for mask := cache.Size >> 1; 0 < mask; mask >>= 1 {
+	for mask := cache.treeSize >> 1; 0 < mask; mask >>= 1 {
 		// do the opposite of IsCached(update: true)
 		cache.evictTree[treeIdx] = (hitElemIdx&mask != 0)
 		// log.Printf("treeIdx: %v\n", treeIdx)
@@ -172,7 +210,9 @@ func (cache *FullAssociativeTreePLRUCache) InvalidateFiveTuple(f *FiveTuple) {
 }
 
 func (cache *FullAssociativeTreePLRUCache) Clear() {
-	panic("Not implemented")
+	cache.Entries = map[FiveTuple]uint{}
+	cache.evictTree = make([]bool, cache.treeSize-1, cache.treeSize-1)
+	cache.entryFromIdx = map[uint]*FiveTuple{}
 }
 
 func (cache *FullAssociativeTreePLRUCache) Description() string {
@@ -180,18 +220,30 @@ func (cache *FullAssociativeTreePLRUCache) Description() string {
 }
 
 func (cache *FullAssociativeTreePLRUCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d}", cache.Description(), cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Size\": %d, \"MaskedLeaves\": %v}", cache.Description(), cache.Size, cache.MaskedLeaves)
 }
 
-func NewFullAssociativeTreePLRUCache(size uint) *FullAssociativeTreePLRUCache {
-	if size != 8 && size != 16 {
-		panic("FullAssociativeTreePLRUCache should have size == 8 or 16")
+// size must be power of two unless maskedLeaves is true
+func NewFullAssociativeTreePLRUCache(size uint, maskedLeaves bool) *FullAssociativeTreePLRUCache {
+	if size == 0 {
+		panic("FullAssociativeTreePLRUCache should have positive size")
+	}
+
+	treeSize := uint(1)
+	for treeSize < size {
+		treeSize <<= 1
+	}
+
+	if treeSize != size && !maskedLeaves {
+		panic("FullAssociativeTreePLRUCache should have size of power of two, or MaskedLeaves")
 	}
 
 	return &FullAssociativeTreePLRUCache{
 		Entries:      map[FiveTuple]uint{},
 		Size:         size,
-		evictTree:    make([]bool, size-1, size-1),
+		MaskedLeaves: maskedLeaves,
+		treeSize:     treeSize,
+		evictTree:    make([]bool, treeSize-1, treeSize-1),
 		entryFromIdx: map[uint]*FiveTuple{},
 	}
 }
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// plruModel is a naive tree-PLRU: nodes are kept by (level, prefix of leaf index),
+// true means the victim is under the right child
+type plruModel struct {
+	size, depth uint
+	right       map[[2]uint]bool
+	leafOf      map[uint16]uint
+	keyAt       map[uint]uint16
+}
+
+func newPLRUModel(size uint) *plruModel {
+	depth := uint(0)
+	for 1<<depth < size {
+		depth += 1
+	}
+
+	return &plruModel{
+		size:   size,
+		depth:  depth,
+		right:  map[[2]uint]bool{},
+		leafOf: map[uint16]uint{},
+		keyAt:  map[uint]uint16{},
+	}
+}
+
+// point makes every node on the path to leaf point away from it (toward if oldest)
+func (m *plruModel) point(leaf uint, oldest bool) {
+	for level := uint(0); level < m.depth; level++ {
+		prefix := leaf >> (m.depth - level)
+		isRight := (leaf>>(m.depth-level-1))&1 == 1
+		m.right[[2]uint{level, prefix}] = isRight == oldest
+	}
+}
+
+func (m *plruModel) victimLeaf(flip bool) uint {
+	prefix := uint(0)
+
+	for level := uint(0); level < m.depth; level++ {
+		node := [2]uint{level, prefix}
+		right := m.right[node]
+
+		// first leaf under the right child
+		if right && m.size <= (prefix<<1|1)<<(m.depth-level-1) {
+			right = false
+		}
+
+		if flip {
+			m.right[node] = !right
+		}
+
+		prefix <<= 1
+		if right {
+			prefix |= 1
+		}
+	}
+
+	return prefix
+}
+
+// access returns whether key hit, and the evicted key if any
+func (m *plruModel) access(key uint16) (bool, *uint16) {
+	if leaf, hit := m.leafOf[key]; hit {
+		m.point(leaf, false)
+		return true, nil
+	}
+
+	leaf := m.victimLeaf(true)
+
+	var evicted *uint16
+	if old, used := m.keyAt[leaf]; used {
+		delete(m.leafOf, old)
+		evicted = &old
+	}
+
+	m.keyAt[leaf] = key
+	m.leafOf[key] = leaf
+
+	return false, evicted
+}
+
+func (m *plruModel) invalidate(key uint16) {
+	leaf, hit := m.leafOf[key]
+	if !hit {
+		return
+	}
+
+	m.point(leaf, true)
+	delete(m.leafOf, key)
+	delete(m.keyAt, leaf)
+}
+
+func plruKey(key uint16) *FiveTuple {
+	return &FiveTuple{Proto: IP_TCP, SrcPort: key, DstPort: 80}
+}
+
+func testTreePLRUCacheWithModel(t *testing.T, size uint, masked bool) {
+	cache := NewFullAssociativeTreePLRUCache(size, masked)
+	model := newPLRUModel(size)
+
+	rng := rand.New(rand.NewSource(int64(size)))
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(4*size))
+
+	for i := 0; i < 20000; i++ {
+		key := uint16(zipf.Uint64())
+		f := plruKey(key)
+
+		if i%97 == 0 {
+			cache.InvalidateFiveTuple(f)
+			model.invalidate(key)
+			continue
+		}
+
+		victim, victimFound := cache.PeekVictim(f)
+
+		hit, _ := cache.IsCachedWithFiveTuple(f, true)
+		var evicted []*FiveTuple
+		if !hit {
+			evicted = cache.CacheFiveTuple(f)
+		}
+
+		expectedHit, expectedEvicted := model.access(key)
+
+		if hit != expectedHit {
+			t.Fatalf("size %d, step %d: hit = %v, expected: %v", size, i, hit, expectedHit)
+		}
+
+		if expectedEvicted == nil {
+			if len(evicted) != 0 {
+				t.Fatalf("size %d, step %d: evicted %v, expected: none", size, i, *evicted[0])
+			}
+
+			if victimFound {
+				t.Fatalf("size %d, step %d: PeekVictim = %v, expected: none", size, i, *victim)
+			}
+		} else {
+			expected := *plruKey(*expectedEvicted)
+
+			if len(evicted) != 1 || *evicted[0] != expected {
+				t.Fatalf("size %d, step %d: evicted %v, expected: %v", size, i, evicted, expected)
+			}
+
+			if !victimFound || *victim != expected {
+				t.Fatalf("size %d, step %d: PeekVictim = %v, expected: %v", size, i, victim, expected)
+			}
+		}
+
+		for idx := range cache.entryFromIdx {
+			if size <= idx {
+				t.Fatalf("size %d, step %d: leaf %d is used", size, i, idx)
+			}
+		}
+
+		if len(cache.Entries) != len(model.leafOf) {
+			t.Fatalf("size %d, step %d: len(Entries) = %d, expected: %d", size, i, len(cache.Entries), len(model.leafOf))
+		}
+	}
+}
+
+func TestFullAssociativeTreePLRUCacheMatchesModel(t *testing.T) {
+	for _, size := range []uint{1, 2, 8, 16, 1024} {
+		testTreePLRUCacheWithModel(t, size, false)
+	}
+}
+
+func TestFullAssociativeTreePLRUCacheMaskedLeaves(t *testing.T) {
+	for _, size := range []uint{3, 5, 12} {
+		testTreePLRUCacheWithModel(t, size, true)
+	}
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"math/bits"
+)
+
+// HashFunction hashes FiveTuple to select a queue or a set
+type HashFunction int
+
+const (
+	HashCRC32     HashFunction = iota // CRC-32 (IEEE) of the serialized FiveTuple
+	HashCRC16                         // CRC-16/CCITT-FALSE of the serialized FiveTuple
+	HashToeplitz                      // Toeplitz hash of RSS with the default key
+	HashXORFold                       // XOR of 32 bit words of the serialized FiveTuple
+	HashBitSelect                     // lower 16 bits of SrcIP and DstIP, DstIP is in lower bits
+	HashFNV                           // FNV-1a (32 bit) of the serialized FiveTuple
+	HashXXHash                        // xxHash32 (seed = 0) of the serialized FiveTuple
+)
+
+var hashFunctionNames = []string{"CRC32", "CRC16", "Toeplitz", "XORFold", "BitSelect", "FNV", "xxHash"}
+
+func (h HashFunction) String() string {
+	if 0 <= int(h) && int(h) < len(hashFunctionNames) {
+		return hashFunctionNames[h]
+	}
+
+	return fmt.Sprintf("HashFunction(%d)", int(h))
+}
+
+func StringToHashFunction(s string) (HashFunction, error) {
+	for i, name := range hashFunctionNames {
+		if s == name {
+			return HashFunction(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown hash function: %s", s)
+}
+
+func (h HashFunction) Hash(f *FiveTuple) uint32 {
+	switch h {
+	case HashCRC32:
+		return crc32.ChecksumIEEE(fiveTupleToBigEndianByteArray(f))
+	case HashCRC16:
+		return uint32(crc16CCITT(fiveTupleToBigEndianByteArray(f)))
+	case HashToeplitz:
+		return toeplitzHash(defaultRSSKey[:], rssInput(f))
+	case HashXORFold:
+		return xorFold(fiveTupleToBigEndianByteArray(f))
+	case HashBitSelect:
+		return uint32(binary.BigEndian.Uint16(f.SrcIP[14:16]))<<16 | uint32(binary.BigEndian.Uint16(f.DstIP[14:16]))
+	case HashFNV:
+		h := fnv.New32a()
+		h.Write(fiveTupleToBigEndianByteArray(f))
+		return h.Sum32()
+	case HashXXHash:
+		return xxHash32(fiveTupleToBigEndianByteArray(f), 0)
+	default:
+		panic(fmt.Sprintf("Unknown hash function: %d", int(h)))
+	}
+}
+
+// SetIdx returns index of the set for f in a cache with numSets sets
+func (h HashFunction) SetIdx(f *FiveTuple, numSets uint) uint {
+	return uint(h.Hash(f)) % numSets
+}
+
+// SetAssociativeCache is implemented by caches which select a set by HashFunction.
+// SetHashFunction must be called before any FiveTuple is cached.
+type SetAssociativeCache interface {
+	Cache
+	SetHashFunction(h HashFunction)
+}
+
+// CRC-16/CCITT-FALSE (polynomial 0x1021, initial value 0xffff)
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xffff)
+
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// xorFold folds data into 32 bits by XOR of big-endian words, the last word is padded by zero
+func xorFold(data []byte) uint32 {
+	var folded uint32
+
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		folded ^= binary.BigEndian.Uint32(word[:])
+	}
+
+	return folded
+}
+
+const (
+	xxHashPrime1 uint32 = 2654435761
+	xxHashPrime2 uint32 = 2246822519
+	xxHashPrime3 uint32 = 3266489917
+	xxHashPrime4 uint32 = 668265263
+	xxHashPrime5 uint32 = 374761393
+)
+
+func xxHash32Round(acc, input uint32) uint32 {
+	return bits.RotateLeft32(acc+input*xxHashPrime2, 13) * xxHashPrime1
+}
+
+func xxHash32(data []byte, seed uint32) uint32 {
+	var h uint32
+	n := len(data)
+
+	if 16 <= n {
+		v1 := seed + xxHashPrime1 + xxHashPrime2
+		v2 := seed + xxHashPrime2
+		v3 := seed
+		v4 := seed - xxHashPrime1
+
+		for ; 16 <= len(data); data = data[16:] {
+			v1 = xxHash32Round(v1, binary.LittleEndian.Uint32(data[0:4]))
+			v2 = xxHash32Round(v2, binary.LittleEndian.Uint32(data[4:8]))
+			v3 = xxHash32Round(v3, binary.LittleEndian.Uint32(data[8:12]))
+			v4 = xxHash32Round(v4, binary.LittleEndian.Uint32(data[12:16]))
+		}
+
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxHashPrime5
+	}
+
+	h += uint32(n)
+
+	for ; 4 <= len(data); data = data[4:] {
+		h += binary.LittleEndian.Uint32(data[0:4]) * xxHashPrime3
+		h = bits.RotateLeft32(h, 17) * xxHashPrime4
+	}
+
+	for _, b := range data {
+		h += uint32(b) * xxHashPrime5
+		h = bits.RotateLeft32(h, 11) * xxHashPrime1
+	}
+
+	h ^= h >> 15
+	h *= xxHashPrime2
+	h ^= h >> 13
+	h *= xxHashPrime3
+	h ^= h >> 16
+
+	return h
+}
+
+// default RSS key of Microsoft RSS specification, also used by many NIC drivers
+var defaultRSSKey = [40]byte{
+	0x6d, 0x5a, 0x56, 0xda, 0x25, 0x5b, 0x0e, 0xc2,
+	0x41, 0x67, 0x25, 0x3d, 0x43, 0xa3, 0x8f, 0xb0,
+	0xd0, 0xca, 0x2b, 0xcb, 0xae, 0x7b, 0x30, 0xb4,
+	0x77, 0xcb, 0x2d, 0xa3, 0x80, 0x30, 0xf2, 0x0c,
+	0x6a, 0x42, 0xb7, 0x3b, 0xbe, 0xac, 0x01, 0xfa,
+}
+
+// rssInput returns input of RSS hash: [srcIP] [dstIP] [srcPort] [dstPort] in network byte order,
+// ports are omitted for protocols other than TCP and UDP
+func rssInput(f *FiveTuple) []byte {
+	ipLen := 16
+	srcIP, dstIP := f.SrcIP[:], f.DstIP[:]
+	if f.SrcIP.IsIPv4() && f.DstIP.IsIPv4() {
+		ipLen = 4
+		srcIP, dstIP = f.SrcIP[12:16], f.DstIP[12:16]
+	}
+
+	buf := make([]byte, 2*ipLen, 2*ipLen+4)
+	copy(buf[:ipLen], srcIP)
+	copy(buf[ipLen:], dstIP)
+
+	if f.Proto == IP_TCP || f.Proto == IP_UDP {
+		buf = buf[:2*ipLen+4]
+		binary.BigEndian.PutUint16(buf[2*ipLen:], f.SrcPort)
+		binary.BigEndian.PutUint16(buf[2*ipLen+2:], f.DstPort)
+	}
+
+	return buf
+}
+
+// toeplitzHash computes Toeplitz hash of data, key must be longer than data by 4 bytes at least
+func toeplitzHash(key []byte, data []byte) uint32 {
+	var hash uint32
+	window := binary.BigEndian.Uint32(key[0:4]) // left-most 32 bits of the key shifted by processed bits
+
+	for i, b := range data {
+		for bit := 7; 0 <= bit; bit-- {
+			if b&(1<<uint(bit)) != 0 {
+				hash ^= window
+			}
+
+			window <<= 1
+			if key[i+4]&(1<<uint(bit)) != 0 {
+				window |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// seededHash gives independent hash functions for each seed (e.g. for each way of SkewedAssociativeCache)
+func seededHash(f *FiveTuple, seed uint32) uint32 {
+	return xxHash32(fiveTupleToBigEndianByteArray(f), seed)
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LatencyModel accumulates lookup latency of MultiLayerCache.
+// Layers are looked up in order, so a lookup hit at layer i costs Latencies[0] + ... + Latencies[i],
+// and a lookup missed in all layers costs sum of Latencies and MissLatency.
+// Unit of latency is up to the config (e.g. cycles or nanoseconds).
+type LatencyModel struct {
+	Latencies   []float64 // lookup latency of each layer
+	MissLatency float64   // latency of the backing store (e.g. slow path) when all layers miss
+
+	TimeByLayer []float64 // total lookup time spent in each layer
+	MissTime    float64   // total time spent in the backing store
+	Lookups     uint
+
+	countByOutcome []uint // [i]: lookups hit at layer i, [len(Latencies)]: lookups missed in all layers
+}
+
+// Record is called on every lookup with index of the hit layer (nil if missed)
+func (lm *LatencyModel) Record(hitLayerIdx *int) {
+	lm.Lookups += 1
+
+	outcome := len(lm.Latencies)
+	if hitLayerIdx != nil {
+		outcome = *hitLayerIdx
+	}
+
+	lm.countByOutcome[outcome] += 1
+
+	for i := 0; i < len(lm.Latencies) && i <= outcome; i++ {
+		lm.TimeByLayer[i] += lm.Latencies[i]
+	}
+
+	if outcome == len(lm.Latencies) {
+		lm.MissTime += lm.MissLatency
+	}
+}
+
+func (lm *LatencyModel) latencyOfOutcome(outcome int) float64 {
+	latency := 0.0
+
+	for i := 0; i < len(lm.Latencies) && i <= outcome; i++ {
+		latency += lm.Latencies[i]
+	}
+
+	if outcome == len(lm.Latencies) {
+		latency += lm.MissLatency
+	}
+
+	return latency
+}
+
+func (lm *LatencyModel) ResetStat() {
+	for i := range lm.TimeByLayer {
+		lm.TimeByLayer[i] = 0
+	}
+
+	for i := range lm.countByOutcome {
+		lm.countByOutcome[i] = 0
+	}
+
+	lm.MissTime = 0
+	lm.Lookups = 0
+}
+
+func (lm *LatencyModel) StatString() string {
+	totalTime := lm.MissTime
+	for _, t := range lm.TimeByLayer {
+		totalTime += t
+	}
+
+	// outcomes with the same latency (e.g. layer with zero latency) share a bin
+	countByLatency := map[float64]uint{}
+	for outcome, count := range lm.countByOutcome {
+		if count != 0 {
+			countByLatency[lm.latencyOfOutcome(outcome)] += count
+		}
+	}
+
+	latencies := make([]float64, 0, len(countByLatency))
+	for latency := range countByLatency {
+		latencies = append(latencies, latency)
+	}
+	sort.Float64s(latencies)
+
+	str := fmt.Sprintf("{\"Mean\": %v, \"Total\": %v, \"TimeByLayer\": [", totalTime/float64(lm.Lookups), totalTime)
+
+	for i, t := range lm.TimeByLayer {
+		if i != 0 {
+			str += ", "
+		}
+
+		str += fmt.Sprintf("%v", t)
+	}
+
+	str += fmt.Sprintf("], \"MissTime\": %v, \"Histogram\": [", lm.MissTime)
+
+	for i, latency := range latencies {
+		if i != 0 {
+			str += ", "
+		}
+
+		str += fmt.Sprintf("{\"Latency\": %v, \"Count\": %d}", latency, countByLatency[latency])
+	}
+
+	str += "]}"
+
+	return str
+}
+
+func NewLatencyModel(latencies []float64, missLatency float64) *LatencyModel {
+	return &LatencyModel{
+		Latencies:      latencies,
+		MissLatency:    missLatency,
+		TimeByLayer:    make([]float64, len(latencies)),
+		countByOutcome: make([]uint, len(latencies)+1),
+	}
+}
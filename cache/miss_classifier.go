@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// MissClassifier classifies misses of a cache by the 3C model:
+// compulsory (first reference of the FiveTuple), capacity (also misses in
+// FullAssociativeLRUCache of the same size) or conflict (the others).
+// The first miss of a FiveTuple after the cache is cleared is counted as flush,
+// and after the FiveTuple is invalidated as invalidation.
+// Entries removed inside the cache (e.g. expirations of CacheWithTimeout) are not told to the classifier
+// unless the cache is in MultiLayerCache with classifiers, so misses after them are counted as capacity or conflict.
+type MissClassifier struct {
+	Compulsory   uint
+	Capacity     uint
+	Conflict     uint
+	Flush        uint
+	Invalidation uint
+
+	seen        map[FiveTuple]uint // FiveTuple -> epoch of the last reference
+	epoch       uint               // incremented on every Clear
+	invalidated map[FiveTuple]struct{}
+	shadow      *FullAssociativeLRUCache
+}
+
+// Record is called on every reference to the cache with whether it hit
+func (mc *MissClassifier) Record(f *FiveTuple, hit bool) {
+	lastEpoch, seen := mc.seen[*f]
+	mc.seen[*f] = mc.epoch
+
+	_, invalidated := mc.invalidated[*f]
+	delete(mc.invalidated, *f)
+
+	shadowHit, _ := mc.shadow.IsCachedWithFiveTuple(f, true)
+	if !shadowHit {
+		mc.shadow.CacheFiveTuple(f)
+	}
+
+	if hit {
+		return
+	}
+
+	switch {
+	case !seen:
+		mc.Compulsory += 1
+	case lastEpoch != mc.epoch:
+		mc.Flush += 1
+	case invalidated:
+		mc.Invalidation += 1
+	case !shadowHit:
+		mc.Capacity += 1
+	default:
+		mc.Conflict += 1
+	}
+}
+
+func (mc *MissClassifier) ResetStat() {
+	mc.Compulsory = 0
+	mc.Capacity = 0
+	mc.Conflict = 0
+	mc.Flush = 0
+	mc.Invalidation = 0
+}
+
+// Clear is called when the classified cache is cleared
+func (mc *MissClassifier) Clear() {
+	mc.epoch += 1
+	mc.invalidated = map[FiveTuple]struct{}{}
+	mc.shadow.Clear()
+}
+
+// Invalidate is called when f is invalidated in the classified cache
+func (mc *MissClassifier) Invalidate(f *FiveTuple) {
+	if _, seen := mc.seen[*f]; seen {
+		mc.invalidated[*f] = struct{}{}
+	}
+
+	mc.shadow.InvalidateFiveTuple(f)
+}
+
+func (mc *MissClassifier) StatString() string {
+	return fmt.Sprintf("{\"Compulsory\": %d, \"Capacity\": %d, \"Conflict\": %d, \"Flush\": %d, \"Invalidation\": %d}", mc.Compulsory, mc.Capacity, mc.Conflict, mc.Flush, mc.Invalidation)
+}
+
+func NewMissClassifier(size uint) *MissClassifier {
+	return &MissClassifier{
+		seen:        map[FiveTuple]uint{},
+		invalidated: map[FiveTuple]struct{}{},
+		shadow:      NewFullAssociativeLRUCache(size),
+	}
+}
@@ -44,6 +44,8 @@ type MultiLayerCache struct {
 	CacheReferedByLayer  []uint
 	CacheReplacedByLayer []uint
 	CacheHitByLayer      []uint
+	MissClassifiers      []*MissClassifier // nil if misses are not classified
+	Latency              *LatencyModel     // nil if latency is not modeled
 }
 
 func (c *MultiLayerCache) StatString() string {
@@ -81,11 +83,73 @@ func (c *MultiLayerCache) StatString() string {
 		str += fmt.Sprintf("%v", x)
 	}
 
-	str += "]}"
+	str += "]"
+
+	if c.MissClassifiers != nil {
+		str += ", \"MissClassification\": ["
+
+		for i, mc := range c.MissClassifiers {
+			if i != 0 {
+				str += ", "
+			}
+
+			str += mc.StatString()
+		}
+
+		str += "]"
+	}
+
+	if c.Latency != nil {
+		str += ", \"Latency\": " + c.Latency.StatString()
+	}
+
+	str += "}"
 
 	return str
 }
 
+func (c *MultiLayerCache) ResetStat() {
+	for i := range c.CacheLayers {
+		c.CacheReferedByLayer[i] = 0
+		c.CacheReplacedByLayer[i] = 0
+		c.CacheHitByLayer[i] = 0
+	}
+
+	for _, mc := range c.MissClassifiers {
+		mc.ResetStat()
+	}
+
+	if c.Latency != nil {
+		c.Latency.ResetStat()
+	}
+
+	for _, cache := range c.CacheLayers {
+		ResetStat(cache)
+	}
+}
+
+// ObservePacket writes back FiveTuples evicted by observation of a layer to lower layers like CacheFiveTuple
+func (c *MultiLayerCache) ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple {
+	evictedFiveTuples := []*FiveTuple{}
+
+	for i, cache := range c.CacheLayers {
+		evictedByLayer := ObservePacket(cache, p, f)
+		c.CacheReplacedByLayer[i] += uint(len(evictedByLayer))
+
+		if i == (len(c.CacheLayers) - 1) {
+			evictedFiveTuples = append(evictedFiveTuples, evictedByLayer...)
+			continue
+		}
+
+		switch c.CachePolicies[i] {
+		case WriteBackExclusive, WriteBackInclusive:
+			evictedFiveTuples = append(evictedFiveTuples, c.cacheIntoLayers(i+1, evictedByLayer)...)
+		}
+	}
+
+	return evictedFiveTuples
+}
+
 func (c *MultiLayerCache) IsCached(p *Packet, update bool) (bool, *int) {
 	return c.IsCachedWithFiveTuple(p.FiveTuple(), update)
 }
@@ -99,7 +163,13 @@ func (c *MultiLayerCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool
 			c.CacheReferedByLayer[i] += 1
 		}
 
-		if hitLayer, _ := cache.IsCachedWithFiveTuple(f, update); hitLayer {
+		hitLayer, _ := cache.IsCachedWithFiveTuple(f, update)
+
+		if update && c.MissClassifiers != nil {
+			c.MissClassifiers[i].Record(f, hitLayer)
+		}
+
+		if hitLayer {
 			if update {
 				c.CacheHitByLayer[i] += 1
 			}
@@ -110,6 +180,10 @@ func (c *MultiLayerCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool
 		}
 	}
 
+	if update && c.Latency != nil {
+		c.Latency.Record(hitLayerIdx)
+	}
+
 	// Update under layer
 	if update && hit {
 		for offset_i, cache := range c.CacheLayers[*hitLayerIdx+1:] {
@@ -141,23 +215,30 @@ func (c *MultiLayerCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool
 }
 
 func (c *MultiLayerCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
-	fiveTuplesToCache := []*FiveTuple{f}
+	return c.cacheIntoLayers(0, []*FiveTuple{f})
+}
+
+// cacheIntoLayers caches FiveTuples from the layer `from` following cache policies,
+// and returns FiveTuples evicted from the last layer
+func (c *MultiLayerCache) cacheIntoLayers(from int, fiveTuplesToCache []*FiveTuple) []*FiveTuple {
 	evictedFiveTuples := []*FiveTuple{}
 
-	for i, cache := range c.CacheLayers {
+	for i := from; i < len(c.CacheLayers); i++ {
+		cache := c.CacheLayers[i]
 		fiveTuplesToCacheNextLayer := []*FiveTuple{}
 
 		for _, f := range fiveTuplesToCache {
-			evictedFiveTuples = cache.CacheFiveTuple(f)
-			c.CacheReplacedByLayer[i] += uint(len(evictedFiveTuples))
+			evictedByLayer := cache.CacheFiveTuple(f)
+			c.CacheReplacedByLayer[i] += uint(len(evictedByLayer))
 
 			if i == (len(c.CacheLayers) - 1) {
+				evictedFiveTuples = append(evictedFiveTuples, evictedByLayer...)
 				continue
 			}
 
 			switch c.CachePolicies[i] {
 			case WriteBackExclusive, WriteBackInclusive:
-				fiveTuplesToCacheNextLayer = append(fiveTuplesToCacheNextLayer, evictedFiveTuples...)
+				fiveTuplesToCacheNextLayer = append(fiveTuplesToCacheNextLayer, evictedByLayer...)
 			case WriteThrough:
 				fiveTuplesToCacheNextLayer = fiveTuplesToCache
 			}
@@ -169,14 +250,26 @@ func (c *MultiLayerCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
 	return evictedFiveTuples
 }
 
+// InvalidateFiveTuple removes the entry from all layers,
+// because it may be cached in several layers with inclusive or write-through policy
 func (c *MultiLayerCache) InvalidateFiveTuple(f *FiveTuple) {
-	panic("not implemented")
+	for _, cache := range c.CacheLayers {
+		cache.InvalidateFiveTuple(f)
+	}
+
+	for _, mc := range c.MissClassifiers {
+		mc.Invalidate(f)
+	}
 }
 
 func (c *MultiLayerCache) Clear() {
 	for _, cache := range c.CacheLayers {
 		cache.Clear()
 	}
+
+	for _, mc := range c.MissClassifiers {
+		mc.Clear()
+	}
 }
 
 func (c *MultiLayerCache) Description() string {
@@ -217,6 +310,22 @@ func (c *MultiLayerCache) ParameterString() string {
 		str += fmt.Sprintf("\"%s\"", cachePolicy.String())
 	}
 
-	str += "]}"
+	str += "]"
+
+	if c.Latency != nil {
+		str += ", \"Latencies\": ["
+
+		for i, latency := range c.Latency.Latencies {
+			if i != 0 {
+				str += ", "
+			}
+
+			str += fmt.Sprintf("%v", latency)
+		}
+
+		str += fmt.Sprintf("], \"MissLatency\": %v", c.Latency.MissLatency)
+	}
+
+	str += "}"
 	return str
 }
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"fmt"
+)
+
+type NWaySetAssociativeARCCache struct {
+	Sets []FullAssociativeARCCache // len(Sets) = Size / Way, each size == Way
+	Way  uint
+	Size uint
+	Hash HashFunction // selects a set
+
+	SetStat *SetStat // nil unless statistics of sets are enabled
+}
+
+func (cache *NWaySetAssociativeARCCache) StatString() string {
+	var t1Len, t2Len, b1Len, b2Len int
+	var targetSum float64
+	var ghostHitB1, ghostHitB2 uint
+
+	for i := range cache.Sets {
+		set := &cache.Sets[i]
+		t1Len += set.t1.Len()
+		t2Len += set.t2.Len()
+		b1Len += set.b1.Len()
+		b2Len += set.b2.Len()
+		targetSum += set.Target
+		ghostHitB1 += set.GhostHitB1
+		ghostHitB2 += set.GhostHitB2
+	}
+
+	str := fmt.Sprintf("{\"T1\": %d, \"T2\": %d, \"B1\": %d, \"B2\": %d, \"MeanTarget\": %v, \"GhostHitB1\": %d, \"GhostHitB2\": %d",
+		t1Len, t2Len, b1Len, b2Len, targetSum/float64(len(cache.Sets)), ghostHitB1, ghostHitB2)
+
+	if cache.SetStat != nil {
+		str += ", " + cache.SetStat.fields(cache.setOccupancy, cache.Way)
+	}
+
+	return str + "}"
+}
+
+func (cache *NWaySetAssociativeARCCache) EnableSetStat() {
+	cache.SetStat = newSetStat(len(cache.Sets))
+}
+
+func (cache *NWaySetAssociativeARCCache) setOccupancy(i int) int {
+	return len(cache.Sets[i].Entries)
+}
+
+func (cache *NWaySetAssociativeARCCache) ResetStat() {
+	for i := range cache.Sets {
+		cache.Sets[i].ResetStat()
+	}
+
+	cache.SetStat.reset()
+}
+
+func (cache *NWaySetAssociativeARCCache) IsCached(p *Packet, update bool) (bool, *int) {
+	return cache.IsCachedWithFiveTuple(p.FiveTuple(), update)
+}
+
+func (cache *NWaySetAssociativeARCCache) setIdxFromFiveTuple(f *FiveTuple) uint {
+	return cache.Hash.SetIdx(f, uint(len(cache.Sets)))
+}
+
+func (cache *NWaySetAssociativeARCCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+
+	if update {
+		cache.SetStat.access(setIdx)
+	}
+
+	return cache.Sets[setIdx].IsCachedWithFiveTuple(f, update) // TODO: return meaningful value
+}
+
+func (cache *NWaySetAssociativeARCCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	return cache.Sets[setIdx].CacheFiveTuple(f)
+}
+
+func (cache *NWaySetAssociativeARCCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	return cache.Sets[setIdx].PeekVictim(f)
+}
+
+func (cache *NWaySetAssociativeARCCache) InvalidateFiveTuple(f *FiveTuple) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	cache.Sets[setIdx].InvalidateFiveTuple(f)
+}
+
+func (cache *NWaySetAssociativeARCCache) Clear() {
+	for i := range cache.Sets {
+		cache.Sets[i].Clear()
+	}
+}
+
+func (cache *NWaySetAssociativeARCCache) SetHashFunction(h HashFunction) {
+	cache.Hash = h
+}
+
+func (cache *NWaySetAssociativeARCCache) Description() string {
+	return "NWaySetAssociativeARCCache"
+}
+
+func (cache *NWaySetAssociativeARCCache) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d, \"Hash\": \"%s\"}", cache.Description(), cache.Way, cache.Size, cache.Hash)
+}
+
+func NewNWaySetAssociativeARCCache(size, way uint) *NWaySetAssociativeARCCache {
+	if size%way != 0 {
+		panic("Size must be multiplier of way")
+	}
+
+	sets_size := size / way
+	sets := make([]FullAssociativeARCCache, sets_size)
+
+	for i := uint(0); i < sets_size; i++ {
+		sets[i] = *NewFullAssociativeARCCache(way)
+	}
+
+	return &NWaySetAssociativeARCCache{
+		Sets: sets,
+		Way:  way,
+		Size: size,
+	}
+}
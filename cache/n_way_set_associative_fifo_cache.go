@@ -2,18 +2,35 @@ package cache
 
 import (
 	"fmt"
-
-	"hash/crc32"
 )
 
 type NWaySetAssociativeFIFOCache struct {
 	Sets []FullAssociativeFIFOCache // len(Sets) = Size / Way, each size == Way
 	Way  uint
 	Size uint
+	Hash HashFunction // selects a set
+
+	SetStat *SetStat // nil unless statistics of sets are enabled
 }
 
 func (cache *NWaySetAssociativeFIFOCache) StatString() string {
-	return ""
+	if cache.SetStat == nil {
+		return ""
+	}
+
+	return "{" + cache.SetStat.fields(cache.setOccupancy, cache.Way) + "}"
+}
+
+func (cache *NWaySetAssociativeFIFOCache) EnableSetStat() {
+	cache.SetStat = newSetStat(len(cache.Sets))
+}
+
+func (cache *NWaySetAssociativeFIFOCache) setOccupancy(i int) int {
+	return len(cache.Sets[i].Entries)
+}
+
+func (cache *NWaySetAssociativeFIFOCache) ResetStat() {
+	cache.SetStat.reset()
 }
 
 func (cache *NWaySetAssociativeFIFOCache) IsCached(p *Packet, update bool) (bool, *int) {
@@ -21,13 +38,16 @@ func (cache *NWaySetAssociativeFIFOCache) IsCached(p *Packet, update bool) (bool
 }
 
 func (cache *NWaySetAssociativeFIFOCache) setIdxFromFiveTuple(f *FiveTuple) uint {
-	maxSetIdx := cache.Size / cache.Way
-	crc := crc32.ChecksumIEEE(fiveTupleToBigEndianByteArray(f))
-	return uint(crc) % maxSetIdx
+	return cache.Hash.SetIdx(f, uint(len(cache.Sets)))
 }
 
 func (cache *NWaySetAssociativeFIFOCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
 	setIdx := cache.setIdxFromFiveTuple(f)
+
+	if update {
+		cache.SetStat.access(setIdx)
+	}
+
 	return cache.Sets[setIdx].IsCachedWithFiveTuple(f, update) // TODO: return meaningful value
 }
 
@@ -36,13 +56,24 @@ func (cache *NWaySetAssociativeFIFOCache) CacheFiveTuple(f *FiveTuple) []*FiveTu
 	return cache.Sets[setIdx].CacheFiveTuple(f)
 }
 
+func (cache *NWaySetAssociativeFIFOCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	return cache.Sets[setIdx].PeekVictim(f)
+}
+
 func (cache *NWaySetAssociativeFIFOCache) InvalidateFiveTuple(f *FiveTuple) {
 	setIdx := cache.setIdxFromFiveTuple(f)
 	cache.Sets[setIdx].InvalidateFiveTuple(f)
 }
 
 func (cache *NWaySetAssociativeFIFOCache) Clear() {
-	panic("Not implemented")
+	for i := range cache.Sets {
+		cache.Sets[i].Clear()
+	}
+}
+
+func (cache *NWaySetAssociativeFIFOCache) SetHashFunction(h HashFunction) {
+	cache.Hash = h
 }
 
 func (cache *NWaySetAssociativeFIFOCache) Description() string {
@@ -50,7 +81,7 @@ func (cache *NWaySetAssociativeFIFOCache) Description() string {
 }
 
 func (cache *NWaySetAssociativeFIFOCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d}", cache.Description(), cache.Way, cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d, \"Hash\": \"%s\"}", cache.Description(), cache.Way, cache.Size, cache.Hash)
 }
 
 func NewNWaySetAssociativeFIFOCache(size, way uint) *NWaySetAssociativeFIFOCache {
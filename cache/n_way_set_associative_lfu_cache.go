@@ -2,18 +2,70 @@ package cache
 
 import (
 	"fmt"
-
-	"hash/crc32"
+	"strings"
 )
 
 type NWaySetAssociativeLFUCache struct {
 	Sets []FullAssociativeLFUCache // len(Sets) = Size / Way, each size == Way
 	Way  uint
 	Size uint
+	Hash HashFunction // selects a set
+
+	Aging *LFUAging // nil if counters are not aged, counters of all sets are halved at once
+
+	SetStat *SetStat // nil unless statistics of sets are enabled
 }
 
 func (cache *NWaySetAssociativeLFUCache) StatString() string {
-	return ""
+	fields := []string{}
+
+	if cache.SetStat != nil {
+		fields = append(fields, cache.SetStat.fields(cache.setOccupancy, cache.Way))
+	}
+
+	if cache.Aging != nil {
+		fields = append(fields, fmt.Sprintf("\"Agings\": %d", cache.Aging.Agings))
+	}
+
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+func (cache *NWaySetAssociativeLFUCache) EnableSetStat() {
+	cache.SetStat = newSetStat(len(cache.Sets))
+}
+
+func (cache *NWaySetAssociativeLFUCache) setOccupancy(i int) int {
+	return len(cache.Sets[i].Entries)
+}
+
+func (cache *NWaySetAssociativeLFUCache) ResetStat() {
+	cache.SetStat.reset()
+
+	if cache.Aging != nil {
+		cache.Aging.Agings = 0
+	}
+}
+
+func (cache *NWaySetAssociativeLFUCache) ObservePacket(p *Packet, f *FiveTuple) []*FiveTuple {
+	if cache.Aging != nil {
+		cache.age(cache.Aging.observe(p.Time))
+	}
+
+	return []*FiveTuple{}
+}
+
+func (cache *NWaySetAssociativeLFUCache) age(shift uint) {
+	if shift == 0 {
+		return
+	}
+
+	for i := range cache.Sets {
+		cache.Sets[i].age(shift)
+	}
 }
 
 func (cache *NWaySetAssociativeLFUCache) IsCached(p *Packet, update bool) (bool, *int) {
@@ -21,13 +73,20 @@ func (cache *NWaySetAssociativeLFUCache) IsCached(p *Packet, update bool) (bool,
 }
 
 func (cache *NWaySetAssociativeLFUCache) setIdxFromFiveTuple(f *FiveTuple) uint {
-	maxSetIdx := cache.Size / cache.Way
-	crc := crc32.ChecksumIEEE(fiveTupleToBigEndianByteArray(f))
-	return uint(crc) % maxSetIdx
+	return cache.Hash.SetIdx(f, uint(len(cache.Sets)))
 }
 
 func (cache *NWaySetAssociativeLFUCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
 	setIdx := cache.setIdxFromFiveTuple(f)
+
+	if update {
+		cache.SetStat.access(setIdx)
+
+		if cache.Aging != nil {
+			cache.age(cache.Aging.access())
+		}
+	}
+
 	return cache.Sets[setIdx].IsCachedWithFiveTuple(f, update) // TODO: return meaningful value
 }
 
@@ -36,13 +95,24 @@ func (cache *NWaySetAssociativeLFUCache) CacheFiveTuple(f *FiveTuple) []*FiveTup
 	return cache.Sets[setIdx].CacheFiveTuple(f)
 }
 
+func (cache *NWaySetAssociativeLFUCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	return cache.Sets[setIdx].PeekVictim(f)
+}
+
 func (cache *NWaySetAssociativeLFUCache) InvalidateFiveTuple(f *FiveTuple) {
 	setIdx := cache.setIdxFromFiveTuple(f)
 	cache.Sets[setIdx].InvalidateFiveTuple(f)
 }
 
 func (cache *NWaySetAssociativeLFUCache) Clear() {
-	panic("Not implemented")
+	for i := range cache.Sets {
+		cache.Sets[i].Clear()
+	}
+}
+
+func (cache *NWaySetAssociativeLFUCache) SetHashFunction(h HashFunction) {
+	cache.Hash = h
 }
 
 func (cache *NWaySetAssociativeLFUCache) Description() string {
@@ -50,7 +120,7 @@ func (cache *NWaySetAssociativeLFUCache) Description() string {
 }
 
 func (cache *NWaySetAssociativeLFUCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d}", cache.Description(), cache.Way, cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d, \"Hash\": \"%s\", \"Aging\": %s}", cache.Description(), cache.Way, cache.Size, cache.Hash, cache.Aging.ParameterString())
 }
 
 func NewNWaySetAssociativeLFUCache(size, way uint) *NWaySetAssociativeLFUCache {
@@ -1,27 +1,36 @@
 package cache
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
-
-	"hash/crc32"
 )
 
 type NWaySetAssociativeLRUCache struct {
 	Sets []FullAssociativeLRUCache // len(Sets) = Size / Way, each size == Way
 	Way  uint
 	Size uint
-}
+	Hash HashFunction // selects a set
 
-func fiveTupleToBigEndianByteArray(f *FiveTuple) []byte {
-	var buf bytes.Buffer
-	binary.Write(&buf, binary.BigEndian, *f)
-	return buf.Bytes()
+	SetStat *SetStat // nil unless statistics of sets are enabled
 }
 
 func (cache *NWaySetAssociativeLRUCache) StatString() string {
-	return ""
+	if cache.SetStat == nil {
+		return ""
+	}
+
+	return "{" + cache.SetStat.fields(cache.setOccupancy, cache.Way) + "}"
+}
+
+func (cache *NWaySetAssociativeLRUCache) EnableSetStat() {
+	cache.SetStat = newSetStat(len(cache.Sets))
+}
+
+func (cache *NWaySetAssociativeLRUCache) setOccupancy(i int) int {
+	return len(cache.Sets[i].Entries)
+}
+
+func (cache *NWaySetAssociativeLRUCache) ResetStat() {
+	cache.SetStat.reset()
 }
 
 func (cache *NWaySetAssociativeLRUCache) IsCached(p *Packet, update bool) (bool, *int) {
@@ -29,13 +38,16 @@ func (cache *NWaySetAssociativeLRUCache) IsCached(p *Packet, update bool) (bool,
 }
 
 func (cache *NWaySetAssociativeLRUCache) setIdxFromFiveTuple(f *FiveTuple) uint {
-	maxSetIdx := cache.Size / cache.Way
-	crc := crc32.ChecksumIEEE(fiveTupleToBigEndianByteArray(f))
-	return uint(crc) % maxSetIdx
+	return cache.Hash.SetIdx(f, uint(len(cache.Sets)))
 }
 
 func (cache *NWaySetAssociativeLRUCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
 	setIdx := cache.setIdxFromFiveTuple(f)
+
+	if update {
+		cache.SetStat.access(setIdx)
+	}
+
 	return cache.Sets[setIdx].IsCachedWithFiveTuple(f, update) // TODO: return meaningful value
 }
 
@@ -44,13 +56,24 @@ func (cache *NWaySetAssociativeLRUCache) CacheFiveTuple(f *FiveTuple) []*FiveTup
 	return cache.Sets[setIdx].CacheFiveTuple(f)
 }
 
+func (cache *NWaySetAssociativeLRUCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	return cache.Sets[setIdx].PeekVictim(f)
+}
+
 func (cache *NWaySetAssociativeLRUCache) InvalidateFiveTuple(f *FiveTuple) {
 	setIdx := cache.setIdxFromFiveTuple(f)
 	cache.Sets[setIdx].InvalidateFiveTuple(f)
 }
 
 func (cache *NWaySetAssociativeLRUCache) Clear() {
-	panic("Not implemented")
+	for i := range cache.Sets {
+		cache.Sets[i].Clear()
+	}
+}
+
+func (cache *NWaySetAssociativeLRUCache) SetHashFunction(h HashFunction) {
+	cache.Hash = h
 }
 
 func (cache *NWaySetAssociativeLRUCache) Description() string {
@@ -58,7 +81,7 @@ func (cache *NWaySetAssociativeLRUCache) Description() string {
 }
 
 func (cache *NWaySetAssociativeLRUCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d}", cache.Description(), cache.Way, cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d, \"Hash\": \"%s\"}", cache.Description(), cache.Way, cache.Size, cache.Hash)
 }
 
 func NewNWaySetAssociativeLRUCache(size, way uint) *NWaySetAssociativeLRUCache {
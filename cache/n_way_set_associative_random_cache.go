@@ -2,14 +2,17 @@ package cache
 
 import (
 	"fmt"
-
-	"hash/crc32"
+	"math/rand"
 )
 
 type NWaySetAssociativeRandomCache struct {
 	Sets []FullAssociativeRandomCache // len(Sets) = Size / Way, each size == Way
 	Way  uint
 	Size uint
+	Seed int64        // seed of rng shared by all sets
+	Hash HashFunction // selects a set
+
+	SetStat *SetStat // nil unless statistics of sets are enabled
 }
 
 // func fiveTupleToBigEndianByteArray(f *FiveTuple) []byte {
@@ -19,7 +22,23 @@ type NWaySetAssociativeRandomCache struct {
 // }
 
 func (cache *NWaySetAssociativeRandomCache) StatString() string {
-	return ""
+	if cache.SetStat == nil {
+		return ""
+	}
+
+	return "{" + cache.SetStat.fields(cache.setOccupancy, cache.Way) + "}"
+}
+
+func (cache *NWaySetAssociativeRandomCache) EnableSetStat() {
+	cache.SetStat = newSetStat(len(cache.Sets))
+}
+
+func (cache *NWaySetAssociativeRandomCache) setOccupancy(i int) int {
+	return len(cache.Sets[i].Entries)
+}
+
+func (cache *NWaySetAssociativeRandomCache) ResetStat() {
+	cache.SetStat.reset()
 }
 
 func (cache *NWaySetAssociativeRandomCache) IsCached(p *Packet, update bool) (bool, *int) {
@@ -27,13 +46,16 @@ func (cache *NWaySetAssociativeRandomCache) IsCached(p *Packet, update bool) (bo
 }
 
 func (cache *NWaySetAssociativeRandomCache) setIdxFromFiveTuple(f *FiveTuple) uint {
-	maxSetIdx := cache.Size / cache.Way
-	crc := crc32.ChecksumIEEE(fiveTupleToBigEndianByteArray(f))
-	return uint(crc) % maxSetIdx
+	return cache.Hash.SetIdx(f, uint(len(cache.Sets)))
 }
 
 func (cache *NWaySetAssociativeRandomCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
 	setIdx := cache.setIdxFromFiveTuple(f)
+
+	if update {
+		cache.SetStat.access(setIdx)
+	}
+
 	return cache.Sets[setIdx].IsCachedWithFiveTuple(f, update) // TODO: return meaningful value
 }
 
@@ -42,13 +64,24 @@ func (cache *NWaySetAssociativeRandomCache) CacheFiveTuple(f *FiveTuple) []*Five
 	return cache.Sets[setIdx].CacheFiveTuple(f)
 }
 
+func (cache *NWaySetAssociativeRandomCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	return cache.Sets[setIdx].PeekVictim(f)
+}
+
 func (cache *NWaySetAssociativeRandomCache) InvalidateFiveTuple(f *FiveTuple) {
 	setIdx := cache.setIdxFromFiveTuple(f)
 	cache.Sets[setIdx].InvalidateFiveTuple(f)
 }
 
 func (cache *NWaySetAssociativeRandomCache) Clear() {
-	panic("Not implemented")
+	for i := range cache.Sets {
+		cache.Sets[i].Clear()
+	}
+}
+
+func (cache *NWaySetAssociativeRandomCache) SetHashFunction(h HashFunction) {
+	cache.Hash = h
 }
 
 func (cache *NWaySetAssociativeRandomCache) Description() string {
@@ -56,24 +89,26 @@ func (cache *NWaySetAssociativeRandomCache) Description() string {
 }
 
 func (cache *NWaySetAssociativeRandomCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d}", cache.Description(), cache.Way, cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d, \"Hash\": \"%s\", \"Seed\": %d}", cache.Description(), cache.Way, cache.Size, cache.Hash, cache.Seed)
 }
 
-func NewNWaySetAssociativeRandomCache(size, way uint) *NWaySetAssociativeRandomCache {
+func NewNWaySetAssociativeRandomCache(size, way uint, seed int64) *NWaySetAssociativeRandomCache {
 	if size%way != 0 {
 		panic("Size must be multiplier of way")
 	}
 
 	sets_size := size / way
 	sets := make([]FullAssociativeRandomCache, sets_size)
+	rng := rand.New(rand.NewSource(seed))
 
 	for i := uint(0); i < sets_size; i++ {
-		sets[i] = *NewFullAssociativeRandomCache(way)
+		sets[i] = *newFullAssociativeRandomCacheWithRand(way, seed, rng)
 	}
 
 	return &NWaySetAssociativeRandomCache{
 		Sets: sets,
 		Way:  way,
 		Size: size,
+		Seed: seed,
 	}
 }
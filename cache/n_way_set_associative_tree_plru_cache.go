@@ -2,14 +2,17 @@ package cache
 
 import (
 	"fmt"
-
-	"hash/crc32"
 )
 
 type NWaySetAssociativeTreePLRUCache struct {
 	Sets []FullAssociativeTreePLRUCache // len(Sets) = Size / Way, each size == Way
 	Way  uint
 	Size uint
+	Hash HashFunction // selects a set
+
+	MaskedLeaves bool // allows Way of not power of two (see FullAssociativeTreePLRUCache)
+
+	SetStat *SetStat // nil unless statistics of sets are enabled
 }
 
 // func fiveTupleToBigEndianByteArray(f *FiveTuple) []byte {
@@ -19,7 +22,23 @@ type NWaySetAssociativeTreePLRUCache struct {
 // }
 
 func (cache *NWaySetAssociativeTreePLRUCache) StatString() string {
-	return ""
+	if cache.SetStat == nil {
+		return ""
+	}
+
+	return "{" + cache.SetStat.fields(cache.setOccupancy, cache.Way) + "}"
+}
+
+func (cache *NWaySetAssociativeTreePLRUCache) EnableSetStat() {
+	cache.SetStat = newSetStat(len(cache.Sets))
+}
+
+func (cache *NWaySetAssociativeTreePLRUCache) setOccupancy(i int) int {
+	return len(cache.Sets[i].Entries)
+}
+
+func (cache *NWaySetAssociativeTreePLRUCache) ResetStat() {
+	cache.SetStat.reset()
 }
 
 func (cache *NWaySetAssociativeTreePLRUCache) IsCached(p *Packet, update bool) (bool, *int) {
@@ -27,13 +46,16 @@ func (cache *NWaySetAssociativeTreePLRUCache) IsCached(p *Packet, update bool) (
 }
 
 func (cache *NWaySetAssociativeTreePLRUCache) setIdxFromFiveTuple(f *FiveTuple) uint {
-	maxSetIdx := cache.Size / cache.Way
-	crc := crc32.ChecksumIEEE(fiveTupleToBigEndianByteArray(f))
-	return uint(crc) % maxSetIdx
+	return cache.Hash.SetIdx(f, uint(len(cache.Sets)))
 }
 
 func (cache *NWaySetAssociativeTreePLRUCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
 	setIdx := cache.setIdxFromFiveTuple(f)
+
+	if update {
+		cache.SetStat.access(setIdx)
+	}
+
 	return cache.Sets[setIdx].IsCachedWithFiveTuple(f, update) // TODO: return meaningful value
 }
 
@@ -42,13 +64,24 @@ func (cache *NWaySetAssociativeTreePLRUCache) CacheFiveTuple(f *FiveTuple) []*Fi
 	return cache.Sets[setIdx].CacheFiveTuple(f)
 }
 
+func (cache *NWaySetAssociativeTreePLRUCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	setIdx := cache.setIdxFromFiveTuple(f)
+	return cache.Sets[setIdx].PeekVictim(f)
+}
+
 func (cache *NWaySetAssociativeTreePLRUCache) InvalidateFiveTuple(f *FiveTuple) {
 	setIdx := cache.setIdxFromFiveTuple(f)
 	cache.Sets[setIdx].InvalidateFiveTuple(f)
 }
 
 func (cache *NWaySetAssociativeTreePLRUCache) Clear() {
-	panic("Not implemented")
+	for i := range cache.Sets {
+		cache.Sets[i].Clear()
+	}
+}
+
+func (cache *NWaySetAssociativeTreePLRUCache) SetHashFunction(h HashFunction) {
+	cache.Hash = h
 }
 
 func (cache *NWaySetAssociativeTreePLRUCache) Description() string {
@@ -56,14 +89,11 @@ func (cache *NWaySetAssociativeTreePLRUCache) Description() string {
 }
 
 func (cache *NWaySetAssociativeTreePLRUCache) ParameterString() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d}", cache.Description(), cache.Way, cache.Size)
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d, \"Hash\": \"%s\", \"MaskedLeaves\": %v}", cache.Description(), cache.Way, cache.Size, cache.Hash, cache.MaskedLeaves)
 }
 
-func NewNWaySetAssociativeTreePLRUCache(size, way uint) *NWaySetAssociativeTreePLRUCache {
-	if way != 8 && way != 16 {
-		panic("Way must be 8 or 16")
-	}
-
+// way must be power of two unless maskedLeaves is true
+func NewNWaySetAssociativeTreePLRUCache(size, way uint, maskedLeaves bool) *NWaySetAssociativeTreePLRUCache {
 	if size%way != 0 {
 		panic("Size must be multiplier of way")
 	}
@@ -72,12 +102,13 @@ func NewNWaySetAssociativeTreePLRUCache(size, way uint) *NWaySetAssociativeTreeP
 	sets := make([]FullAssociativeTreePLRUCache, sets_size)
 
 	for i := uint(0); i < sets_size; i++ {
-		sets[i] = *NewFullAssociativeTreePLRUCache(way)
+		sets[i] = *NewFullAssociativeTreePLRUCache(way, maskedLeaves)
 	}
 
 	return &NWaySetAssociativeTreePLRUCache{
-		Sets: sets,
-		Way:  way,
-		Size: size,
+		Sets:         sets,
+		Way:          way,
+		Size:         size,
+		MaskedLeaves: maskedLeaves,
 	}
 }
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+)
+
+// OPTOracle knows when each FiveTuple will be referred in the future.
+// References must be registered with AddReference in a pre-pass over the whole trace,
+// then the trace is replayed calling Advance for every packet (in the same order).
+type OPTOracle struct {
+	Now int // position of the packet currently processed, -1 before replay
+
+	references map[FiveTuple][]int // positions where the FiveTuple is referred
+	cursors    map[FiveTuple]int   // index of references[f] which is the next reference
+	length     int
+
+	caches []*FullAssociativeOPTCache
+}
+
+const optNeverReferred = math.MaxInt64
+
+func (o *OPTOracle) AddReference(f *FiveTuple) {
+	o.references[*f] = append(o.references[*f], o.length)
+	o.length += 1
+}
+
+func (o *OPTOracle) Len() int {
+	return o.length
+}
+
+// Advance moves the clock to the next packet, whose FiveTuple must be f
+func (o *OPTOracle) Advance(f *FiveTuple) {
+	o.Now += 1
+
+	refs := o.references[*f]
+	cursor := o.cursors[*f]
+
+	if len(refs) <= cursor || refs[cursor] != o.Now {
+		panic(fmt.Sprintf("replayed trace differs from the pre-pass at position %d: %v", o.Now, *f))
+	}
+
+	o.cursors[*f] = cursor + 1
+
+	for _, c := range o.caches {
+		c.updateNextUse(f)
+	}
+}
+
+// NextUse returns the position of the next reference of f after Now
+func (o *OPTOracle) NextUse(f *FiveTuple) int {
+	refs := o.references[*f]
+	cursor := o.cursors[*f]
+
+	if len(refs) <= cursor {
+		return optNeverReferred
+	}
+
+	return refs[cursor]
+}
+
+func (o *OPTOracle) HasCaches() bool {
+	return len(o.caches) != 0
+}
+
+func (o *OPTOracle) register(c *FullAssociativeOPTCache) {
+	o.caches = append(o.caches, c)
+}
+
+func NewOPTOracle() *OPTOracle {
+	return &OPTOracle{
+		Now:        -1,
+		references: map[FiveTuple][]int{},
+		cursors:    map[FiveTuple]int{},
+	}
+}
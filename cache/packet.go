@@ -11,6 +11,7 @@ type Packet struct {
 	Proto            string
 	SrcIP, DstIP     net.IP
 	SrcPort, DstPort uint16
+	DNSAnswers       []net.IP // addresses in A/AAAA records if the packet is a DNS response (only from pcap)
 	// IcmpType, IcmpCode uint16
 }
 
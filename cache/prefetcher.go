@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// Prefetcher predicts FiveTuples which will be looked up soon, for CacheWithLookAhead
+type Prefetcher interface {
+	// ObservePacket is called for every packet before its lookup, and returns FiveTuples to prefetch
+	ObservePacket(p *Packet, f *FiveTuple) []FiveTuple
+	// Miss is called when f is cached on a miss, and returns FiveTuples to prefetch
+	Miss(f *FiveTuple) []FiveTuple
+
+	Description() string
+	ParameterString() string
+}
+
+// ReverseFlowPrefetcher prefetches the reverse flow of a new flow, as replies follow requests
+type ReverseFlowPrefetcher struct {
+	Protos []IPProtocol
+}
+
+func (pf *ReverseFlowPrefetcher) ObservePacket(p *Packet, f *FiveTuple) []FiveTuple {
+	return nil
+}
+
+func (pf *ReverseFlowPrefetcher) Miss(f *FiveTuple) []FiveTuple {
+	for _, proto := range pf.Protos {
+		if f.Proto == proto {
+			return []FiveTuple{f.SwapSrcAndDst()}
+		}
+	}
+
+	return nil
+}
+
+func (pf *ReverseFlowPrefetcher) Description() string {
+	return "ReverseFlow"
+}
+
+func (pf *ReverseFlowPrefetcher) ParameterString() string {
+	str := fmt.Sprintf("{\"Type\": \"%s\", \"Protos\": [", pf.Description())
+
+	for i, proto := range pf.Protos {
+		if i != 0 {
+			str += ", "
+		}
+
+		switch proto {
+		case IP_TCP:
+			str += "\"tcp\""
+		case IP_UDP:
+			str += "\"udp\""
+		default:
+			str += fmt.Sprintf("%d", proto)
+		}
+	}
+
+	return str + "]}"
+}
+
+// DNSPrefetcher prefetches flows from the client to addresses resolved by DNS responses.
+// Source port of the flow is unknown, so prefetched FiveTuples are useful only when
+// KeyExtractor doesn't use SrcPort (Packet.DNSAnswers is given only from pcap).
+type DNSPrefetcher struct {
+	Proto        string // protocol of the flow to the resolved address
+	DstPort      uint16
+	KeyExtractor *FlowKeyExtractor
+}
+
+func (pf *DNSPrefetcher) ObservePacket(p *Packet, f *FiveTuple) []FiveTuple {
+	if len(p.DNSAnswers) == 0 {
+		return nil
+	}
+
+	clientIsIPv4 := p.DstIP.To4() != nil
+	fiveTuples := []FiveTuple{}
+
+	for _, addr := range p.DNSAnswers {
+		if (addr.To4() != nil) != clientIsIPv4 {
+			continue
+		}
+
+		key := pf.KeyExtractor.Extract(&Packet{
+			Time:    p.Time,
+			Proto:   pf.Proto,
+			SrcIP:   p.DstIP,
+			DstIP:   addr,
+			DstPort: pf.DstPort,
+		})
+
+		if key != nil {
+			fiveTuples = append(fiveTuples, *key)
+		}
+	}
+
+	return fiveTuples
+}
+
+func (pf *DNSPrefetcher) Miss(f *FiveTuple) []FiveTuple {
+	return nil
+}
+
+func (pf *DNSPrefetcher) Description() string {
+	return "DNS"
+}
+
+func (pf *DNSPrefetcher) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Proto\": \"%s\", \"DstPort\": %d}", pf.Description(), pf.Proto, pf.DstPort)
+}
+
+// MarkovPrefetcher learns which new flow follows a new flow (first order Markov chain of misses),
+// and prefetches the most frequent Candidates successors of a new flow.
+// At most MaxSuccessors successors are kept for each flow, the least frequent one is replaced by a new one.
+type MarkovPrefetcher struct {
+	Candidates    uint
+	MaxSuccessors uint
+
+	successors map[FiveTuple][]markovSuccessor // in descending order of Count
+	last       FiveTuple
+	hasLast    bool
+}
+
+type markovSuccessor struct {
+	FiveTuple FiveTuple
+	Count     uint
+}
+
+func (pf *MarkovPrefetcher) ObservePacket(p *Packet, f *FiveTuple) []FiveTuple {
+	return nil
+}
+
+func (pf *MarkovPrefetcher) learn(from, to *FiveTuple) {
+	successors := pf.successors[*from]
+
+	i := 0
+	for i < len(successors) && successors[i].FiveTuple != *to {
+		i++
+	}
+
+	switch {
+	case i < len(successors):
+		successors[i].Count += 1
+	case len(successors) < int(pf.MaxSuccessors):
+		successors = append(successors, markovSuccessor{FiveTuple: *to, Count: 1})
+	default:
+		i = len(successors) - 1
+		successors[i] = markovSuccessor{FiveTuple: *to, Count: 1}
+	}
+
+	// successor seen earlier goes first when counts are the same
+	for ; 0 < i && successors[i-1].Count < successors[i].Count; i-- {
+		successors[i-1], successors[i] = successors[i], successors[i-1]
+	}
+
+	pf.successors[*from] = successors
+}
+
+func (pf *MarkovPrefetcher) Miss(f *FiveTuple) []FiveTuple {
+	if pf.hasLast && pf.last != *f {
+		pf.learn(&pf.last, f)
+	}
+
+	pf.last = *f
+	pf.hasLast = true
+
+	fiveTuples := []FiveTuple{}
+
+	for i, successor := range pf.successors[*f] {
+		if i == int(pf.Candidates) {
+			break
+		}
+
+		fiveTuples = append(fiveTuples, successor.FiveTuple)
+	}
+
+	return fiveTuples
+}
+
+func (pf *MarkovPrefetcher) Description() string {
+	return "Markov"
+}
+
+func (pf *MarkovPrefetcher) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Candidates\": %d, \"MaxSuccessors\": %d}", pf.Description(), pf.Candidates, pf.MaxSuccessors)
+}
+
+func NewMarkovPrefetcher(candidates, maxSuccessors uint) *MarkovPrefetcher {
+	if candidates == 0 || maxSuccessors < candidates {
+		panic("MarkovPrefetcher needs positive Candidates, and MaxSuccessors not less than Candidates")
+	}
+
+	return &MarkovPrefetcher{
+		Candidates:    candidates,
+		MaxSuccessors: maxSuccessors,
+		successors:    map[FiveTuple][]markovSuccessor{},
+	}
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetStatCollector is a set-associative cache which can collect statistics of its sets
+type SetStatCollector interface {
+	Cache
+	EnableSetStat()
+}
+
+// SetStat counts lookups of each set of a set-associative cache.
+// It is nil unless enabled, as printing it costs O(number of sets).
+type SetStat struct {
+	accesses []uint // number of lookups of each set
+}
+
+func newSetStat(numSets int) *SetStat {
+	return &SetStat{accesses: make([]uint, numSets)}
+}
+
+func (s *SetStat) access(setIdx uint) {
+	if s != nil {
+		s.accesses[setIdx] += 1
+	}
+}
+
+func (s *SetStat) reset() {
+	if s == nil {
+		return
+	}
+
+	for i := range s.accesses {
+		s.accesses[i] = 0
+	}
+}
+
+// fields returns JSON fields (without braces) of statistics of sets,
+// occupancy(i) returns number of entries in the i-th set
+func (s *SetStat) fields(occupancy func(i int) int, way uint) string {
+	occupancies := make([]int, len(s.accesses))
+	for i := range occupancies {
+		occupancies[i] = occupancy(i)
+	}
+
+	return setStatFields(occupancies, s.accesses, way)
+}
+
+// setStatFields returns JSON fields (without braces) of statistics of sets in set-associative caches:
+// occupancy (number of entries) and accesses of each set
+func setStatFields(occupancies []int, accesses []uint, way uint) string {
+	histogram := make([]int, way+1) // histogram[n]: number of sets with n entries
+	occupancySum := 0
+
+	for _, occupancy := range occupancies {
+		histogram[occupancy] += 1
+		occupancySum += occupancy
+	}
+
+	str := fmt.Sprintf("\"SetOccupancy\": {\"Mean\": %v, \"Histogram\": [", float64(occupancySum)/float64(len(occupancies)))
+
+	for i, n := range histogram {
+		if i != 0 {
+			str += ", "
+		}
+
+		str += fmt.Sprintf("%d", n)
+	}
+
+	str += "]}, "
+
+	var accessSum, accessMax uint
+	accessMin := uint(math.MaxUint32)
+
+	for _, access := range accesses {
+		accessSum += access
+
+		if accessMax < access {
+			accessMax = access
+		}
+
+		if access < accessMin {
+			accessMin = access
+		}
+	}
+
+	accessMean := float64(accessSum) / float64(len(accesses))
+
+	squaredSum := 0.0
+	for _, access := range accesses {
+		squaredSum += (float64(access) - accessMean) * (float64(access) - accessMean)
+	}
+
+	imbalance := 0.0
+	if accessSum != 0 {
+		imbalance = float64(accessMax) / accessMean
+	}
+
+	str += fmt.Sprintf("\"SetAccess\": {\"Mean\": %v, \"Min\": %d, \"Max\": %d, \"StdDev\": %v, \"Imbalance\": %v}",
+		accessMean, accessMin, accessMax, math.Sqrt(squaredSum/float64(len(accesses))), imbalance)
+
+	return str
+}
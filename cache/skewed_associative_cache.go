@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"fmt"
+)
+
+// Skewed-associative cache (Seznec, ISCA '93): each way is indexed by its own hash function,
+// so FiveTuples conflicting in a way are likely to be placed in different sets of the other ways.
+// An entry is replaced by LRU among the candidates of the ways.
+type SkewedAssociativeCache struct {
+	Entries map[FiveTuple]skewedAssociativeCacheLocation
+	Size    uint
+	Way     uint
+
+	Insertions uint
+	Evictions  uint
+
+	ways  [][]skewedAssociativeCacheSlot // len(ways) == Way, each len == Size / Way
+	clock uint64
+}
+
+type skewedAssociativeCacheLocation struct {
+	Way, Idx uint
+}
+
+type skewedAssociativeCacheSlot struct {
+	FiveTuple  FiveTuple
+	Used       bool
+	LastAccess uint64
+}
+
+func (cache *SkewedAssociativeCache) StatString() string {
+	return fmt.Sprintf("{\"Entries\": %d, \"Insertions\": %d, \"Evictions\": %d}", len(cache.Entries), cache.Insertions, cache.Evictions)
+}
+
+func (cache *SkewedAssociativeCache) ResetStat() {
+	cache.Insertions = 0
+	cache.Evictions = 0
+}
+
+func (cache *SkewedAssociativeCache) AssertImmutableCondition() {
+	if int(cache.Size) < len(cache.Entries) {
+		panic(fmt.Sprintln("len(cache.Entries):", len(cache.Entries), ", expected: less than or equal to", cache.Size))
+	}
+}
+
+// idx of the slot for f in the way
+func (cache *SkewedAssociativeCache) slotIdx(f *FiveTuple, way uint) uint {
+	return uint(seededHash(f, uint32(way))) % uint(len(cache.ways[way]))
+}
+
+// victimLocation returns an empty candidate of f first, otherwise least recently used candidate
+func (cache *SkewedAssociativeCache) victimLocation(f *FiveTuple) skewedAssociativeCacheLocation {
+	var victim skewedAssociativeCacheLocation
+	found := false
+
+	for way := uint(0); way < cache.Way; way++ {
+		idx := cache.slotIdx(f, way)
+		slot := &cache.ways[way][idx]
+
+		if !slot.Used {
+			return skewedAssociativeCacheLocation{way, idx}
+		}
+
+		if !found || slot.LastAccess < cache.ways[victim.Way][victim.Idx].LastAccess {
+			victim = skewedAssociativeCacheLocation{way, idx}
+			found = true
+		}
+	}
+
+	return victim
+}
+
+func (cache *SkewedAssociativeCache) IsCached(p *Packet, update bool) (bool, *int) {
+	return cache.IsCachedWithFiveTuple(p.FiveTuple(), update)
+}
+
+func (cache *SkewedAssociativeCache) IsCachedWithFiveTuple(f *FiveTuple, update bool) (bool, *int) {
+	loc, hit := cache.Entries[*f]
+
+	if hit && update {
+		cache.clock += 1
+		cache.ways[loc.Way][loc.Idx].LastAccess = cache.clock
+	}
+
+	return hit, nil
+}
+
+func (cache *SkewedAssociativeCache) CacheFiveTuple(f *FiveTuple) []*FiveTuple {
+	cache.AssertImmutableCondition()
+
+	evictedFiveTuples := []*FiveTuple{}
+
+	if hit, _ := cache.IsCachedWithFiveTuple(f, true); hit {
+		return evictedFiveTuples
+	}
+
+	victim := cache.victimLocation(f)
+	slot := &cache.ways[victim.Way][victim.Idx]
+
+	if slot.Used {
+		evictedFiveTuple := slot.FiveTuple
+		delete(cache.Entries, evictedFiveTuple)
+		evictedFiveTuples = append(evictedFiveTuples, &evictedFiveTuple)
+		cache.Evictions += 1
+	}
+
+	cache.clock += 1
+	*slot = skewedAssociativeCacheSlot{
+		FiveTuple:  *f,
+		Used:       true,
+		LastAccess: cache.clock,
+	}
+	cache.Entries[*f] = victim
+	cache.Insertions += 1
+
+	cache.AssertImmutableCondition()
+
+	return evictedFiveTuples
+}
+
+func (cache *SkewedAssociativeCache) PeekVictim(f *FiveTuple) (*FiveTuple, bool) {
+	if _, hit := cache.Entries[*f]; hit {
+		return nil, false
+	}
+
+	loc := cache.victimLocation(f)
+	slot := &cache.ways[loc.Way][loc.Idx]
+
+	if !slot.Used {
+		return nil, false
+	}
+
+	victim := slot.FiveTuple
+	return &victim, true
+}
+
+func (cache *SkewedAssociativeCache) InvalidateFiveTuple(f *FiveTuple) {
+	loc, hit := cache.Entries[*f]
+
+	if !hit {
+		return
+	}
+
+	cache.ways[loc.Way][loc.Idx] = skewedAssociativeCacheSlot{}
+	delete(cache.Entries, *f)
+}
+
+func (cache *SkewedAssociativeCache) Clear() {
+	cache.Entries = map[FiveTuple]skewedAssociativeCacheLocation{}
+
+	for way := range cache.ways {
+		for idx := range cache.ways[way] {
+			cache.ways[way][idx] = skewedAssociativeCacheSlot{}
+		}
+	}
+}
+
+func (cache *SkewedAssociativeCache) Description() string {
+	return "SkewedAssociativeCache"
+}
+
+func (cache *SkewedAssociativeCache) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Way\": %d, \"Size\": %d}", cache.Description(), cache.Way, cache.Size)
+}
+
+func NewSkewedAssociativeCache(size, way uint) *SkewedAssociativeCache {
+	if size%way != 0 {
+		panic("Size must be multiplier of way")
+	}
+
+	ways := make([][]skewedAssociativeCacheSlot, way)
+	for i := range ways {
+		ways[i] = make([]skewedAssociativeCacheSlot, size/way)
+	}
+
+	return &SkewedAssociativeCache{
+		Entries: map[FiveTuple]skewedAssociativeCacheLocation{},
+		Size:    size,
+		Way:     way,
+		ways:    ways,
+	}
+}
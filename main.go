@@ -57,8 +57,15 @@ func parseCSVRecord(record []string) (*cache.Packet, error) {
 	}
 	packet.Len = uint32(packetLen)
 
-	packet.SrcIP = net.ParseIP(recordSrcIPStr)
-	packet.DstIP = net.ParseIP(recordDstIPStr)
+	// IPv4 or IPv6 address, IPv6 address may be enclosed by brackets
+	packet.SrcIP = net.ParseIP(strings.Trim(recordSrcIPStr, "[]"))
+	if packet.SrcIP == nil {
+		return nil, fmt.Errorf("invalid source IP address: %s", recordSrcIPStr)
+	}
+	packet.DstIP = net.ParseIP(strings.Trim(recordDstIPStr, "[]"))
+	if packet.DstIP == nil {
+		return nil, fmt.Errorf("invalid destination IP address: %s", recordDstIPStr)
+	}
 	packet.Proto = strings.ToLower(recordProtoStr)
 
 	switch packet.Proto {
@@ -74,7 +81,7 @@ func parseCSVRecord(record []string) (*cache.Packet, error) {
 			return nil, err
 		}
 		packet.DstPort = uint16(dstPort)
-	case "icmp":
+	case "icmp", "icmpv6":
 		// icmpType, err := strconv.ParseUint(record[5], 10, 16)
 		// if err != nil {
 		// 	return nil, err
@@ -124,14 +131,14 @@ func getProperCSVReader(fp *os.File) *csv.Reader {
 	return nil
 }
 
-func runSimpleCacheSimulatorWithCSV(fp *os.File, sim *simulator.SimpleCacheSimulator, printInterval int) {
+func forEachPacketInCSV(fp *os.File, fn func(p *cache.Packet)) {
 	reader := getProperCSVReader(fp)
 
 	if reader == nil {
 		panic("Can't read input as valid tsv/csv file")
 	}
 
-	for i := 0; ; i += 1 {
+	for {
 		record, err := reader.Read()
 
 		if err != nil {
@@ -149,32 +156,79 @@ func runSimpleCacheSimulatorWithCSV(fp *os.File, sim *simulator.SimpleCacheSimul
 		}
 
 		packet, err := parseCSVRecord(record)
-		if packet.Proto == "icmp" {
-			// ignore icmp packet
-			continue
-		}
-
 		if err != nil {
 			fmt.Println("Error:", err)
 			continue
 			// panic(err)
 		}
 
+		if packet.Proto == "icmp" || packet.Proto == "icmpv6" {
+			// ignore icmp packet
+			continue
+		}
+
 		if packet.FiveTuple() == nil {
 			continue
 		}
 
+		fn(packet)
+	}
+}
+
+// forEachPacket reads pcap, pcapng or tsv/csv file
+func forEachPacket(fp *os.File, fn func(p *cache.Packet)) {
+	if isPcapFile(fp) {
+		forEachPacketInPcap(fp, fn)
+	} else {
+		forEachPacketInCSV(fp, fn)
+	}
+}
+
+func runSimpleCacheSimulator(fp *os.File, sim *simulator.SimpleCacheSimulator) {
+	if sim.Oracle != nil {
+		// pre-pass: OPT cache needs to know future references
+		forEachPacket(fp, func(packet *cache.Packet) {
+			sim.Oracle.AddReference(sim.KeyExtractor.Extract(packet))
+		})
+	}
+
+	forEachPacket(fp, func(packet *cache.Packet) {
 		sim.Process(packet)
-		if sim.GetStat().Processed%printInterval == 0 {
+		if sim.PrintInterval != 0 && sim.GetStat().Processed%sim.PrintInterval == 0 {
 			fmt.Printf("%v\n", sim.GetStatString())
 		}
+	})
+
+	if err := sim.Finish(); err != nil {
+		panic(err)
 	}
 }
 
+func runSweepSimulator(fp *os.File, sweep *simulator.SweepSimulator) {
+	oracleSims := []*simulator.SimpleCacheSimulator{}
+	for _, sim := range sweep.Simulators {
+		if sim.Oracle != nil {
+			oracleSims = append(oracleSims, sim)
+		}
+	}
+
+	if len(oracleSims) != 0 {
+		// pre-pass: OPT cache needs to know future references
+		forEachPacket(fp, func(packet *cache.Packet) {
+			for _, sim := range oracleSims {
+				sim.Oracle.AddReference(sim.KeyExtractor.Extract(packet))
+			}
+		})
+	}
+
+	forEachPacket(fp, sweep.Process)
+	sweep.Wait()
+}
+
 func main() {
 
 	if len(os.Args) != 2 && len(os.Args) != 3 {
-		fmt.Printf("%s cacheparam [tsv]\n", os.Args[0])
+		fmt.Printf("%s cacheparam [tsv|pcap|pcapng]\n", os.Args[0])
 		os.Exit(1)
 	}
 
@@ -189,27 +243,80 @@ func main() {
 		panic(err)
 	}
 
-	cacheSim, err := simulator.BuildSimpleCacheSimulator(simlatorDefinition)
-
+	simulatorType, err := simulator.DefinitionType(simlatorDefinition)
 	if err != nil {
 		panic(err)
 	}
 
-	var fpCSV *os.File
+	var fpTrace *os.File
 
 	if len(os.Args) == 2 {
-		fpCSV = os.Stdin
+		fpTrace = os.Stdin
 	} else {
 		var err error
-		fpCSV, err = os.Open(os.Args[2])
+		fpTrace, err = os.Open(os.Args[2])
 
 		if err != nil {
 			panic(err)
 		}
-		defer fpCSV.Close()
+		defer fpTrace.Close()
 	}
 
-	runSimpleCacheSimulatorWithCSV(fpCSV, cacheSim, 1)
+	switch simulatorType {
+	case "SweepSimulator":
+		sweep, err := simulator.BuildSweepSimulator(simlatorDefinition)
+		if err != nil {
+			panic(err)
+		}
+
+		runSweepSimulator(fpTrace, sweep)
+
+		for _, stat := range sweep.GetStatStrings() {
+			fmt.Printf("%v\n", stat)
+		}
+	case "MultiSeedSimulator":
+		multiSeed, err := simulator.BuildMultiSeedSimulator(simlatorDefinition)
+		if err != nil {
+			panic(err)
+		}
+
+		runSweepSimulator(fpTrace, multiSeed.SweepSimulator)
+
+		for _, stat := range multiSeed.GetStatStrings() {
+			fmt.Printf("%v\n", stat)
+		}
+	case "MultiQueueCacheSimulator":
+		multiQueue, err := simulator.BuildMultiQueueCacheSimulator(simlatorDefinition)
+		if err != nil {
+			panic(err)
+		}
+
+		forEachPacket(fpTrace, func(packet *cache.Packet) {
+			multiQueue.Process(packet)
+		})
 
-	fmt.Printf("%v\n", cacheSim.GetStatString())
+		if err := multiQueue.Finish(); err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("%v\n", multiQueue.GetStatString())
+	case "StackDistanceAnalyzer":
+		analyzer, err := simulator.BuildStackDistanceAnalyzer(simlatorDefinition)
+		if err != nil {
+			panic(err)
+		}
+
+		forEachPacket(fpTrace, analyzer.Process)
+
+		fmt.Printf("%v\n", analyzer.GetStatString())
+	default:
+		cacheSim, err := simulator.BuildSimpleCacheSimulator(simlatorDefinition)
+		if err != nil {
+			panic(err)
+		}
+
+		runSimpleCacheSimulator(fpTrace, cacheSim)
+
+		fmt.Printf("%v\n", cacheSim.GetStatString())
+	}
 }
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+const (
+	pcapMagicMicroseconds = 0xa1b2c3d4
+	pcapMagicNanoseconds  = 0xa1b23c4d
+	pcapngBlockTypeSHB    = 0x0a0d0d0a
+	pcapngByteOrderMagic  = 0x1a2b3c4d
+
+	pcapngBlockTypeIDB = 0x00000001
+	pcapngBlockTypePB  = 0x00000002 // obsolete Packet Block
+	pcapngBlockTypeSPB = 0x00000003
+	pcapngBlockTypeEPB = 0x00000006
+
+	pcapngOptionEndOfOpt  = 0
+	pcapngOptionIfTsresol = 9
+)
+
+// https://www.tcpdump.org/linktypes.html
+const (
+	linkTypeNull      = 0
+	linkTypeEthernet  = 1
+	linkTypeRaw       = 101
+	linkTypeLinuxSLL  = 113
+	linkTypeIPv4      = 228
+	linkTypeIPv6      = 229
+	linkTypeLinuxSLL2 = 276
+)
+
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86dd
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88a8
+	etherTypeQinQ2 = 0x9100
+)
+
+type pcapngInterface struct {
+	linkType    uint16
+	snapLen     uint32
+	tsPerSecond float64
+}
+
+func isPcapFile(fp *os.File) bool {
+	magic := make([]byte, 4)
+
+	n, _ := io.ReadFull(fp, magic)
+	fp.Seek(0, 0)
+
+	if n != 4 {
+		return false
+	}
+
+	switch binary.LittleEndian.Uint32(magic) {
+	case pcapMagicMicroseconds, pcapMagicNanoseconds, pcapngBlockTypeSHB:
+		return true
+	}
+
+	switch binary.BigEndian.Uint32(magic) {
+	case pcapMagicMicroseconds, pcapMagicNanoseconds:
+		return true
+	}
+
+	return false
+}
+
+func forEachPacketInPcap(fp *os.File, fn func(p *cache.Packet)) {
+	fp.Seek(0, 0)
+	reader := bufio.NewReaderSize(fp, 1<<20)
+
+	magic, err := reader.Peek(4)
+	if err != nil {
+		panic(err)
+	}
+
+	if binary.LittleEndian.Uint32(magic) == pcapngBlockTypeSHB {
+		err = readPcapng(reader, fn)
+	} else {
+		err = readPcap(reader, fn)
+	}
+
+	if err != nil && err != io.EOF {
+		fmt.Println("Error:", err)
+	}
+}
+
+func readPcap(reader io.Reader, fn func(p *cache.Packet)) error {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return err
+	}
+
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	magic := byteOrder.Uint32(header[0:4])
+	if magic != pcapMagicMicroseconds && magic != pcapMagicNanoseconds {
+		byteOrder = binary.BigEndian
+		magic = byteOrder.Uint32(header[0:4])
+	}
+
+	tsPerSecond := 1e6
+	if magic == pcapMagicNanoseconds {
+		tsPerSecond = 1e9
+	}
+
+	linkType := uint16(byteOrder.Uint32(header[20:24]))
+
+	recordHeader := make([]byte, 16)
+	data := []byte{}
+
+	for {
+		if _, err := io.ReadFull(reader, recordHeader); err != nil {
+			return err
+		}
+
+		tsSec := byteOrder.Uint32(recordHeader[0:4])
+		tsFrac := byteOrder.Uint32(recordHeader[4:8])
+		capLen := byteOrder.Uint32(recordHeader[8:12])
+		origLen := byteOrder.Uint32(recordHeader[12:16])
+
+		if uint32(cap(data)) < capLen {
+			data = make([]byte, capLen)
+		}
+		data = data[:capLen]
+
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return err
+		}
+
+		if packet := decodeLinkLayer(linkType, data); packet != nil {
+			packet.Time = float64(tsSec) + float64(tsFrac)/tsPerSecond
+			packet.Len = origLen
+			fn(packet)
+		}
+	}
+}
+
+func readPcapng(reader io.Reader, fn func(p *cache.Packet)) error {
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	interfaces := []pcapngInterface{}
+
+	blockHeader := make([]byte, 8)
+	body := []byte{}
+
+	for {
+		if _, err := io.ReadFull(reader, blockHeader); err != nil {
+			return err
+		}
+
+		blockType := byteOrder.Uint32(blockHeader[0:4])
+
+		if binary.LittleEndian.Uint32(blockHeader[0:4]) == pcapngBlockTypeSHB {
+			// byte order may change at every section
+			blockType = pcapngBlockTypeSHB
+
+			bom := make([]byte, 4)
+			if _, err := io.ReadFull(reader, bom); err != nil {
+				return err
+			}
+
+			if binary.LittleEndian.Uint32(bom) == pcapngByteOrderMagic {
+				byteOrder = binary.LittleEndian
+			} else if binary.BigEndian.Uint32(bom) == pcapngByteOrderMagic {
+				byteOrder = binary.BigEndian
+			} else {
+				return fmt.Errorf("invalid pcapng byte-order magic: %x", bom)
+			}
+
+			interfaces = interfaces[:0]
+
+			blockLen := byteOrder.Uint32(blockHeader[4:8])
+			if blockLen < 16 {
+				return fmt.Errorf("invalid pcapng block length: %d", blockLen)
+			}
+			if _, err := io.CopyN(ioutil.Discard, reader, int64(blockLen-12)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		blockLen := byteOrder.Uint32(blockHeader[4:8])
+		if blockLen < 12 || blockLen%4 != 0 {
+			return fmt.Errorf("invalid pcapng block length: %d", blockLen)
+		}
+
+		bodyLen := blockLen - 12
+		if uint32(cap(body)) < bodyLen+4 {
+			body = make([]byte, bodyLen+4)
+		}
+		body = body[:bodyLen+4] // including trailing block length
+
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return err
+		}
+		body = body[:bodyLen]
+
+		switch blockType {
+		case pcapngBlockTypeIDB:
+			if len(body) < 8 {
+				return fmt.Errorf("too short interface description block")
+			}
+
+			iface := pcapngInterface{
+				linkType:    byteOrder.Uint16(body[0:2]),
+				snapLen:     byteOrder.Uint32(body[4:8]),
+				tsPerSecond: 1e6,
+			}
+
+			forEachPcapngOption(byteOrder, body[8:], func(code uint16, value []byte) {
+				if code == pcapngOptionIfTsresol && len(value) == 1 {
+					if value[0]&0x80 == 0 {
+						iface.tsPerSecond = math.Pow10(int(value[0]))
+					} else {
+						iface.tsPerSecond = math.Exp2(float64(value[0] & 0x7f))
+					}
+				}
+			})
+
+			interfaces = append(interfaces, iface)
+		case pcapngBlockTypeEPB, pcapngBlockTypePB:
+			if len(body) < 20 {
+				return fmt.Errorf("too short packet block")
+			}
+
+			var ifaceID uint32
+			if blockType == pcapngBlockTypeEPB {
+				ifaceID = byteOrder.Uint32(body[0:4])
+			} else {
+				ifaceID = uint32(byteOrder.Uint16(body[0:2]))
+			}
+
+			if len(interfaces) <= int(ifaceID) {
+				return fmt.Errorf("packet block refers unknown interface: %d", ifaceID)
+			}
+			iface := interfaces[ifaceID]
+
+			ts := uint64(byteOrder.Uint32(body[4:8]))<<32 | uint64(byteOrder.Uint32(body[8:12]))
+			capLen := byteOrder.Uint32(body[12:16])
+			origLen := byteOrder.Uint32(body[16:20])
+
+			if uint32(len(body)-20) < capLen {
+				return fmt.Errorf("captured length %d exceeds block", capLen)
+			}
+
+			if packet := decodeLinkLayer(iface.linkType, body[20:20+capLen]); packet != nil {
+				packet.Time = float64(ts) / iface.tsPerSecond
+				packet.Len = origLen
+				fn(packet)
+			}
+		case pcapngBlockTypeSPB:
+			if len(body) < 4 || len(interfaces) == 0 {
+				return fmt.Errorf("invalid simple packet block")
+			}
+
+			// simple packet block has no timestamp
+			iface := interfaces[0]
+			origLen := byteOrder.Uint32(body[0:4])
+			capLen := uint32(len(body) - 4)
+			if origLen < capLen {
+				capLen = origLen
+			}
+			if iface.snapLen != 0 && iface.snapLen < capLen {
+				capLen = iface.snapLen
+			}
+
+			if packet := decodeLinkLayer(iface.linkType, body[4:4+capLen]); packet != nil {
+				packet.Len = origLen
+				fn(packet)
+			}
+		default:
+			// ignore other blocks (name resolution, statistics, ...)
+		}
+	}
+}
+
+func forEachPcapngOption(byteOrder binary.ByteOrder, options []byte, fn func(code uint16, value []byte)) {
+	for 4 <= len(options) {
+		code := byteOrder.Uint16(options[0:2])
+		length := int(byteOrder.Uint16(options[2:4]))
+
+		if code == pcapngOptionEndOfOpt || len(options) < 4+length {
+			return
+		}
+
+		fn(code, options[4:4+length])
+
+		padded := (length + 3) &^ 3
+		if len(options) < 4+padded {
+			return
+		}
+		options = options[4+padded:]
+	}
+}
+
+// returns nil if the packet is not TCP/UDP over IPv4/IPv6
+func decodeLinkLayer(linkType uint16, data []byte) *cache.Packet {
+	switch linkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil
+		}
+
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ || etherType == etherTypeQinQ2 {
+			if len(data) < 4 {
+				return nil
+			}
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+
+		return decodeNetworkLayer(etherType, data)
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil
+		}
+		return decodeNetworkLayer(binary.BigEndian.Uint16(data[14:16]), data[16:])
+	case linkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return nil
+		}
+		return decodeNetworkLayer(binary.BigEndian.Uint16(data[0:2]), data[20:])
+	case linkTypeNull:
+		if len(data) < 4 {
+			return nil
+		}
+
+		// address family in host byte order of capturing machine
+		family := binary.LittleEndian.Uint32(data[0:4])
+		if 0xffff < family {
+			family = binary.BigEndian.Uint32(data[0:4])
+		}
+
+		switch family {
+		case 2: // AF_INET
+			return decodeNetworkLayer(etherTypeIPv4, data[4:])
+		case 10, 24, 28, 30: // AF_INET6 (Linux, BSDs, macOS)
+			return decodeNetworkLayer(etherTypeIPv6, data[4:])
+		default:
+			return nil
+		}
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		if len(data) < 1 {
+			return nil
+		}
+
+		switch data[0] >> 4 {
+		case 4:
+			return decodeNetworkLayer(etherTypeIPv4, data)
+		case 6:
+			return decodeNetworkLayer(etherTypeIPv6, data)
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+}
+
+func decodeNetworkLayer(etherType uint16, data []byte) *cache.Packet {
+	packet := new(cache.Packet)
+	var proto cache.IPProtocol
+
+	switch etherType {
+	case etherTypeIPv4:
+		if len(data) < 20 {
+			return nil
+		}
+
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return nil
+		}
+
+		// non-first fragment doesn't have L4 header
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 {
+			return nil
+		}
+
+		proto = cache.IPProtocol(data[9])
+		packet.SrcIP = net.IP(append([]byte{}, data[12:16]...))
+		packet.DstIP = net.IP(append([]byte{}, data[16:20]...))
+		data = data[ihl:]
+	case etherTypeIPv6:
+		if len(data) < 40 {
+			return nil
+		}
+
+		nextHeader := data[6]
+		packet.SrcIP = net.IP(append([]byte{}, data[8:24]...))
+		packet.DstIP = net.IP(append([]byte{}, data[24:40]...))
+		data = data[40:]
+
+		// skip extension headers
+	extensionHeaders:
+		for {
+			switch nextHeader {
+			case 0, 43, 60: // Hop-by-Hop, Routing, Destination Options
+				if len(data) < 8 {
+					return nil
+				}
+				length := (int(data[1]) + 1) * 8
+				if len(data) < length {
+					return nil
+				}
+				nextHeader = data[0]
+				data = data[length:]
+			case 44: // Fragment
+				if len(data) < 8 {
+					return nil
+				}
+				if binary.BigEndian.Uint16(data[2:4])&0xfff8 != 0 {
+					return nil
+				}
+				nextHeader = data[0]
+				data = data[8:]
+			case 51: // Authentication Header
+				if len(data) < 8 {
+					return nil
+				}
+				length := (int(data[1]) + 2) * 4
+				if len(data) < length {
+					return nil
+				}
+				nextHeader = data[0]
+				data = data[length:]
+			default:
+				break extensionHeaders
+			}
+		}
+
+		proto = cache.IPProtocol(nextHeader)
+	default:
+		return nil
+	}
+
+	switch proto {
+	case cache.IP_TCP:
+		packet.Proto = "tcp"
+	case cache.IP_UDP:
+		packet.Proto = "udp"
+	default:
+		return nil
+	}
+
+	if len(data) < 4 {
+		return nil
+	}
+
+	packet.SrcPort = binary.BigEndian.Uint16(data[0:2])
+	packet.DstPort = binary.BigEndian.Uint16(data[2:4])
+
+	if packet.SrcPort == dnsPort {
+		packet.DNSAnswers = decodeDNSAnswers(proto, data)
+	}
+
+	return packet
+}
+
+const (
+	dnsPort       = 53
+	dnsTypeA      = 1
+	dnsTypeAAAA   = 28
+	dnsHeaderSize = 12
+)
+
+// decodeDNSAnswers returns addresses in A/AAAA records of DNS response in the segment,
+// the message over TCP must be in a segment
+func decodeDNSAnswers(proto cache.IPProtocol, segment []byte) []net.IP {
+	var msg []byte
+
+	switch proto {
+	case cache.IP_UDP:
+		if len(segment) < 8 {
+			return nil
+		}
+		msg = segment[8:]
+	case cache.IP_TCP:
+		if len(segment) < 13 {
+			return nil
+		}
+
+		dataOffset := int(segment[12]>>4) * 4
+		if len(segment) < dataOffset+2 {
+			return nil
+		}
+
+		// message is prefixed by its length
+		msg = segment[dataOffset+2:]
+	default:
+		return nil
+	}
+
+	// QR bit is 1 for response
+	if len(msg) < dnsHeaderSize || msg[2]&0x80 == 0 {
+		return nil
+	}
+
+	qdCount := int(binary.BigEndian.Uint16(msg[4:6]))
+	anCount := int(binary.BigEndian.Uint16(msg[6:8]))
+	offset := dnsHeaderSize
+
+	for i := 0; i < qdCount; i++ {
+		// name, type, class
+		offset = skipDNSName(msg, offset)
+		if offset < 0 || len(msg) < offset+4 {
+			return nil
+		}
+		offset += 4
+	}
+
+	var answers []net.IP
+
+	for i := 0; i < anCount; i++ {
+		// name, type, class, TTL, RDLENGTH, RDATA
+		offset = skipDNSName(msg, offset)
+		if offset < 0 || len(msg) < offset+10 {
+			break
+		}
+
+		rrType := binary.BigEndian.Uint16(msg[offset : offset+2])
+		rdLength := int(binary.BigEndian.Uint16(msg[offset+8 : offset+10]))
+		offset += 10
+
+		if len(msg) < offset+rdLength {
+			break
+		}
+
+		if (rrType == dnsTypeA && rdLength == net.IPv4len) || (rrType == dnsTypeAAAA && rdLength == net.IPv6len) {
+			answers = append(answers, net.IP(append([]byte{}, msg[offset:offset+rdLength]...)))
+		}
+
+		offset += rdLength
+	}
+
+	return answers
+}
+
+// skipDNSName returns offset next to the (possibly compressed) name, or -1 if it is malformed
+func skipDNSName(msg []byte, offset int) int {
+	for offset < len(msg) {
+		length := int(msg[offset])
+
+		switch {
+		case length == 0:
+			return offset + 1
+		case length&0xc0 == 0xc0:
+			// pointer to the rest of the name
+			if len(msg) < offset+2 {
+				return -1
+			}
+			return offset + 2
+		case length&0xc0 != 0:
+			return -1
+		}
+
+		offset += 1 + length
+	}
+
+	return -1
+}
@@ -2,19 +2,104 @@ package simulator
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/kyontan/cache_simulator/cache"
 )
 
+type TrafficStat struct {
+	Processed      int
+	ProcessedBytes int
+	Hit            int
+	HitBytes       int
+}
+
+func (ts TrafficStat) String() string {
+	return fmt.Sprintf("{\"Processed\": %v, \"ProcessedBytes\": %v, \"Hit\": %v, \"HitBytes\": %v, \"HitRate\": %v, \"ByteHitRate\": %v}", ts.Processed, ts.ProcessedBytes, ts.Hit, ts.HitBytes, float64(ts.Hit)/float64(ts.Processed), float64(ts.HitBytes)/float64(ts.ProcessedBytes))
+}
+
+func (ts *TrafficStat) count(p *cache.Packet, hit bool) {
+	ts.Processed += 1
+	ts.ProcessedBytes += int(p.Len)
+
+	if hit {
+		ts.Hit += 1
+		ts.HitBytes += int(p.Len)
+	}
+}
+
+func trafficStatsString(stats map[string]TrafficStat) string {
+	keys := make([]string, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	str := "{"
+
+	for i, k := range keys {
+		if i != 0 {
+			str += ", "
+		}
+
+		str += fmt.Sprintf("\"%s\": %v", k, stats[k])
+	}
+
+	str += "}"
+
+	return str
+}
+
+// IANA port number ranges
+func dstPortRange(port uint16) string {
+	switch {
+	case port < 1024:
+		return "WellKnown"
+	case port < 49152:
+		return "Registered"
+	default:
+		return "Dynamic"
+	}
+}
+
 type CacheSimulatorStat struct {
-	Type      string
-	Parameter string
-	Processed int
-	Hit       int
+	Type           string
+	Parameter      string
+	Key            string // parameter of FlowKeyExtractor
+	Processed      int
+	Hit            int
+	ProcessedBytes int
+	HitBytes       int
+	UniqueKeys     int
+	ByProto        map[string]TrafficStat
+	ByDstPort      map[string]TrafficStat // by range of destination port
+}
+
+// Count counts the packet processed
+func (css *CacheSimulatorStat) Count(p *cache.Packet, hit bool) {
+	css.Processed += 1
+	css.ProcessedBytes += int(p.Len)
+
+	if hit {
+		css.Hit += 1
+		css.HitBytes += int(p.Len)
+	}
+
+	protoStat := css.ByProto[p.Proto]
+	protoStat.count(p, hit)
+	css.ByProto[p.Proto] = protoStat
+
+	portRange := dstPortRange(p.DstPort)
+	portStat := css.ByDstPort[portRange]
+	portStat.count(p, hit)
+	css.ByDstPort[portRange] = portStat
 }
 
 func (css CacheSimulatorStat) String() string {
-	return fmt.Sprintf("{\"Type\": \"%s\", \"Parameter\": %s, \"Processed\": %v, \"Hit\": %v, \"HitRate\": %v}", css.Type, css.Parameter, css.Processed, css.Hit, float64(css.Hit)/float64(css.Processed))
+	return fmt.Sprintf("{\"Type\": \"%s\", \"Parameter\": %s, \"Key\": %s, \"Processed\": %v, \"Hit\": %v, \"HitRate\": %v, \"ProcessedBytes\": %v, \"HitBytes\": %v, \"ByteHitRate\": %v, \"UniqueKeys\": %v, \"ByProto\": %s, \"ByDstPort\": %s}",
+		css.Type, css.Parameter, css.Key, css.Processed, css.Hit, float64(css.Hit)/float64(css.Processed),
+		css.ProcessedBytes, css.HitBytes, float64(css.HitBytes)/float64(css.ProcessedBytes), css.UniqueKeys,
+		trafficStatsString(css.ByProto), trafficStatsString(css.ByDstPort))
 }
 
 type CacheSimulator interface {
@@ -0,0 +1,60 @@
+package simulator
+
+import (
+	"github.com/koron/go-dproxy"
+)
+
+func isNotFound(err error) bool {
+	dErr, ok := err.(dproxy.Error)
+	return ok && dErr.ErrorType() == dproxy.Enotfound
+}
+
+// optionalInt64 returns defaultValue if the key does not exist
+func optionalInt64(p dproxy.Proxy, defaultValue int64) (int64, error) {
+	v, err := p.Int64()
+	if isNotFound(err) {
+		return defaultValue, nil
+	}
+	return v, err
+}
+
+// optionalFloat64 returns defaultValue if the key does not exist
+func optionalFloat64(p dproxy.Proxy, defaultValue float64) (float64, error) {
+	v, err := p.Float64()
+	if isNotFound(err) {
+		return defaultValue, nil
+	}
+	return v, err
+}
+
+// optionalString returns defaultValue if the key does not exist
+func optionalString(p dproxy.Proxy, defaultValue string) (string, error) {
+	v, err := p.String()
+	if isNotFound(err) {
+		return defaultValue, nil
+	}
+	return v, err
+}
+
+// optionalBool returns defaultValue if the key does not exist
+func optionalBool(p dproxy.Proxy, defaultValue bool) (bool, error) {
+	v, err := p.Bool()
+	if isNotFound(err) {
+		return defaultValue, nil
+	}
+	return v, err
+}
+
+// DefinitionType returns "Type" of simulator (or analyzer) definition
+func DefinitionType(json interface{}) (string, error) {
+	return dproxy.New(json).M("Type").String()
+}
+
+// appendJSONField adds `"key": value` to JSON object (or makes new object if empty)
+func appendJSONField(object, key, value string) string {
+	if object == "" || object == "{}" {
+		return "{\"" + key + "\": " + value + "}"
+	}
+
+	return object[:len(object)-1] + ", \"" + key + "\": " + value + "}"
+}
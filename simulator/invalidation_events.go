@@ -0,0 +1,129 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// InvalidationEvents replays removals of cache entries (e.g. rule deletions by SDN controller)
+// in order of Packet.Time, before the packet processed at the same time or later
+type InvalidationEvents struct {
+	Events      []*cache.Packet // sorted by Time, only Time and fields of five-tuple are used
+	Applied     int             // number of replayed events
+	Invalidated int             // number of replayed events which removed a cached entry
+
+	next int
+}
+
+// Replay invalidates entries of events until now, returns keys of replayed events
+func (ie *InvalidationEvents) Replay(now float64, c cache.Cache, keyExtractor *cache.FlowKeyExtractor) []*cache.FiveTuple {
+	keys := []*cache.FiveTuple{}
+
+	for ; ie.next < len(ie.Events) && ie.Events[ie.next].Time <= now; ie.next++ {
+		ie.Applied += 1
+
+		f := keyExtractor.Extract(ie.Events[ie.next])
+
+		if cached, _ := c.IsCachedWithFiveTuple(f, false); cached {
+			ie.Invalidated += 1
+		}
+
+		c.InvalidateFiveTuple(f)
+		keys = append(keys, f)
+	}
+
+	return keys
+}
+
+func (ie *InvalidationEvents) StatString() string {
+	return fmt.Sprintf("{\"Events\": %d, \"Applied\": %d, \"Invalidated\": %d}", len(ie.Events), ie.Applied, ie.Invalidated)
+}
+
+// parseInvalidationEvent parses a record: [time] [proto] [srcIP] [dstIP] [srcPort] [dstPort]
+func parseInvalidationEvent(record []string) (*cache.Packet, error) {
+	if len(record) != 6 {
+		return nil, fmt.Errorf("Expected record have 6 fields, but not: %d", len(record))
+	}
+
+	event := new(cache.Packet)
+	var err error
+
+	event.Time, err = strconv.ParseFloat(record[0], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Proto = strings.ToLower(record[1])
+	if event.Proto != "tcp" && event.Proto != "udp" {
+		return nil, fmt.Errorf("unknown proto: %s", event.Proto)
+	}
+
+	// IPv4 or IPv6 address, IPv6 address may be enclosed by brackets
+	event.SrcIP = net.ParseIP(strings.Trim(record[2], "[]"))
+	if event.SrcIP == nil {
+		return nil, fmt.Errorf("invalid source IP address: %s", record[2])
+	}
+	event.DstIP = net.ParseIP(strings.Trim(record[3], "[]"))
+	if event.DstIP == nil {
+		return nil, fmt.Errorf("invalid destination IP address: %s", record[3])
+	}
+
+	srcPort, err := strconv.ParseUint(record[4], 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	event.SrcPort = uint16(srcPort)
+
+	dstPort, err := strconv.ParseUint(record[5], 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	event.DstPort = uint16(dstPort)
+
+	return event, nil
+}
+
+// LoadInvalidationEvents reads events from CSV file, lines starting with '#' are ignored
+func LoadInvalidationEvents(path string) (*InvalidationEvents, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	reader := csv.NewReader(fp)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	ie := &InvalidationEvents{}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		event, err := parseInvalidationEvent(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v: %v", path, record, err)
+		}
+
+		ie.Events = append(ie.Events, event)
+	}
+
+	sort.SliceStable(ie.Events, func(i, j int) bool {
+		return ie.Events[i].Time < ie.Events[j].Time
+	})
+
+	return ie, nil
+}
@@ -0,0 +1,244 @@
+package simulator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/koron/go-dproxy"
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+const defaultIndirectionTableSize = 128
+
+// MultiQueueCacheSimulator distributes packets to queues by hash of the five-tuple like RSS of NICs.
+// Each queue has a private cache, and may share a cache behind them.
+type MultiQueueCacheSimulator struct {
+	Queues      []*SimpleCacheSimulator // Cache of each queue is MultiLayerCache[private, shared] if SharedCache is not nil
+	Hash        cache.HashFunction
+	SharedCache cache.Cache // nil if there is no shared cache
+
+	// queue of hash value, indexed by hash % len(IndirectionTable) (RETA of RSS)
+	IndirectionTable []int
+}
+
+func (mq *MultiQueueCacheSimulator) Process(p *cache.Packet) bool {
+	f := p.FiveTuple()
+	if f == nil {
+		return false
+	}
+
+	queueIdx := mq.IndirectionTable[mq.Hash.Hash(f)%uint32(len(mq.IndirectionTable))]
+
+	return mq.Queues[queueIdx].Process(p)
+}
+
+// Finish is called after all packets are processed
+func (mq *MultiQueueCacheSimulator) Finish() error {
+	for _, sim := range mq.Queues {
+		if err := sim.Finish(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mq *MultiQueueCacheSimulator) GetStatString() string {
+	var processed, hit, maxProcessed int
+
+	for _, sim := range mq.Queues {
+		processed += sim.Stat.Processed
+		hit += sim.Stat.Hit
+
+		if maxProcessed < sim.Stat.Processed {
+			maxProcessed = sim.Stat.Processed
+		}
+	}
+
+	meanProcessed := float64(processed) / float64(len(mq.Queues))
+
+	stat := fmt.Sprintf("{\"Type\": \"MultiQueueCacheSimulator\", \"Queues\": %d, \"Hash\": \"%s\", \"IndirectionTableSize\": %d, \"Processed\": %d, \"Hit\": %d, \"HitRate\": %v, \"LoadImbalance\": %v",
+		len(mq.Queues), mq.Hash, len(mq.IndirectionTable), processed, hit, float64(hit)/float64(processed), float64(maxProcessed)/meanProcessed)
+
+	if mq.SharedCache == nil {
+		stat += ", \"SharedCache\": null"
+	} else {
+		stat += ", \"SharedCache\": {\"Parameter\": " + mq.SharedCache.ParameterString()
+
+		if statDetail := mq.SharedCache.StatString(); statDetail != "" {
+			stat += ", \"StatDetail\": " + statDetail
+		}
+
+		stat += "}"
+	}
+
+	stat += ", \"QueueStats\": ["
+
+	for i, sim := range mq.Queues {
+		if i != 0 {
+			stat += ", "
+		}
+
+		stat += sim.GetStatString()
+	}
+
+	stat += "]}"
+
+	return stat
+}
+
+// keys of MultiQueueCacheSimulator, the others are passed to SimpleCacheSimulator of each queue
+var multiQueueCacheSimulatorKeys = []string{"Type", "Queues", "Hash", "IndirectionTableSize", "PrivateCache", "SharedCache", "SharedCachePolicy"}
+
+// "Queues": 4, "Hash": "Toeplitz", "PrivateCache": {...}, "SharedCache": {...}, "SharedCachePolicy": "WriteBackInclusive"
+func BuildMultiQueueCacheSimulator(json interface{}) (*MultiQueueCacheSimulator, error) {
+	p := dproxy.New(json)
+
+	simType, err := p.M("Type").String()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if simType != "MultiQueueCacheSimulator" {
+		return nil, fmt.Errorf("Unsupported simulator type: %s", simType)
+	}
+
+	queues, err := p.M("Queues").Int64()
+	if err != nil {
+		return nil, err
+	}
+
+	if queues <= 0 {
+		return nil, fmt.Errorf("`Queues` must be positive: %d", queues)
+	}
+
+	hashStr, err := optionalString(p.M("Hash"), "Toeplitz")
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := cache.StringToHashFunction(hashStr)
+	if err != nil {
+		return nil, err
+	}
+
+	indirectionTableSize, err := optionalInt64(p.M("IndirectionTableSize"), defaultIndirectionTableSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if indirectionTableSize < queues {
+		return nil, fmt.Errorf("`IndirectionTableSize` must be larger than or equal to `Queues`: %d", indirectionTableSize)
+	}
+
+	// each queue sees only its own packets, options driven by the packet sequence can't be applied per queue
+	for _, key := range []string{"Window", "Flush", "WarmUp", "InvalidationEvents"} {
+		if _, err := p.M(key).Value(); err == nil {
+			return nil, fmt.Errorf("`%s` is not supported by MultiQueueCacheSimulator", key)
+		}
+	}
+
+	definition, err := p.Map()
+	if err != nil {
+		return nil, err
+	}
+
+	queueDefinition := deepCopyDefinition(definition).(map[string]interface{})
+	for _, key := range multiQueueCacheSimulatorKeys {
+		delete(queueDefinition, key)
+	}
+
+	queueDefinition["Type"] = "SimpleCacheSimulator"
+	queueDefinition["Cache"], err = p.M("PrivateCache").Value()
+	if err != nil {
+		return nil, err
+	}
+
+	mq := &MultiQueueCacheSimulator{
+		Hash:             hash,
+		IndirectionTable: make([]int, indirectionTableSize),
+	}
+
+	// queues are assigned to the table in round robin, as default of NIC drivers
+	for i := range mq.IndirectionTable {
+		mq.IndirectionTable[i] = i % int(queues)
+	}
+
+	var sharedCachePolicy cache.CachePolicy
+
+	// seeds are given to the shared cache and caches of each queue in order, so they don't share random sequence
+	seed, err := optionalInt64(p.M("Seed"), time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.M("SharedCache").Value(); err == nil {
+		keyExtractor, err := buildFlowKeyExtractor(p.M("Key"))
+		if err != nil {
+			return nil, err
+		}
+
+		ctx := &cacheBuildContext{
+			oracle:       cache.NewOPTOracle(),
+			keyExtractor: keyExtractor,
+			seed:         seed,
+		}
+
+		mq.SharedCache, err = buildCache(p.M("SharedCache"), ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		seed = ctx.seed
+
+		if ctx.oracle.HasCaches() {
+			return nil, fmt.Errorf("OPT cache is not supported by MultiQueueCacheSimulator")
+		}
+
+		sharedCachePolicyStr, err := optionalString(p.M("SharedCachePolicy"), "WriteBackInclusive")
+		if err != nil {
+			return nil, err
+		}
+
+		sharedCachePolicy = cache.StringToCachePolicy(sharedCachePolicyStr)
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	for i := 0; i < int(queues); i++ {
+		queueDefinition["Seed"] = seed
+
+		sim, err := BuildSimpleCacheSimulator(deepCopyDefinition(queueDefinition))
+		if err != nil {
+			return nil, err
+		}
+
+		seed = sim.nextSeed
+
+		if sim.Oracle != nil {
+			return nil, fmt.Errorf("OPT cache is not supported by MultiQueueCacheSimulator")
+		}
+
+		if mq.SharedCache != nil {
+			if sim.MissClassifier != nil {
+				return nil, fmt.Errorf("`ClassifyMisses` is not supported with `SharedCache`")
+			}
+
+			sim.Cache = &cache.MultiLayerCache{
+				CacheLayers:          []cache.Cache{sim.Cache, mq.SharedCache},
+				CachePolicies:        []cache.CachePolicy{sharedCachePolicy},
+				CacheReferedByLayer:  make([]uint, 2),
+				CacheReplacedByLayer: make([]uint, 2),
+				CacheHitByLayer:      make([]uint, 2),
+			}
+
+			sim.Stat.Type = sim.Cache.Description()
+			sim.Stat.Parameter = sim.Cache.ParameterString()
+		}
+
+		mq.Queues = append(mq.Queues, sim)
+	}
+
+	return mq, nil
+}
@@ -0,0 +1,162 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/koron/go-dproxy"
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// two-sided 95% quantiles of Student's t-distribution, index is degrees of freedom
+var tQuantile95 = []float64{
+	math.NaN(), 12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+func tQuantile95ByDegreesOfFreedom(df int) float64 {
+	if df < len(tQuantile95) {
+		return tQuantile95[df]
+	}
+
+	return 1.960
+}
+
+// SampleSummary is mean, sample standard deviation and 95% confidence interval of the mean
+type SampleSummary struct {
+	Mean   float64
+	StdDev float64
+	CILow  float64
+	CIHigh float64
+}
+
+func (s SampleSummary) String() string {
+	return fmt.Sprintf("{\"Mean\": %v, \"StdDev\": %v, \"CI95Low\": %v, \"CI95High\": %v}", s.Mean, s.StdDev, s.CILow, s.CIHigh)
+}
+
+// summarizeSamples needs two samples at least
+func summarizeSamples(samples []float64) SampleSummary {
+	n := float64(len(samples))
+
+	sum := 0.0
+	for _, x := range samples {
+		sum += x
+	}
+	mean := sum / n
+
+	squaredSum := 0.0
+	for _, x := range samples {
+		squaredSum += (x - mean) * (x - mean)
+	}
+	stdDev := math.Sqrt(squaredSum / (n - 1))
+
+	halfWidth := tQuantile95ByDegreesOfFreedom(len(samples)-1) * stdDev / math.Sqrt(n)
+
+	return SampleSummary{
+		Mean:   mean,
+		StdDev: stdDev,
+		CILow:  mean - halfWidth,
+		CIHigh: mean + halfWidth,
+	}
+}
+
+// MultiSeedSimulator runs a SimpleCacheSimulator with different seeds of random caches
+type MultiSeedSimulator struct {
+	*SweepSimulator
+	Seeds []int64 // Seeds[i] is the seed of Simulators[i]
+}
+
+func (s *MultiSeedSimulator) StatString() string {
+	hitRates := make([]float64, len(s.Simulators))
+	byteHitRates := make([]float64, len(s.Simulators))
+
+	for i, sim := range s.Simulators {
+		hitRates[i] = float64(sim.Stat.Hit) / float64(sim.Stat.Processed)
+		byteHitRates[i] = float64(sim.Stat.HitBytes) / float64(sim.Stat.ProcessedBytes)
+	}
+
+	seedStrs := make([]string, len(s.Seeds))
+	for i, seed := range s.Seeds {
+		seedStrs[i] = fmt.Sprintf("%d", seed)
+	}
+
+	return fmt.Sprintf("{\"Type\": \"MultiSeedSimulator\", \"Cache\": \"%s\", \"Seeds\": [%s], \"HitRate\": %v, \"ByteHitRate\": %v}",
+		s.Simulators[0].Stat.Type, strings.Join(seedStrs, ", "), summarizeSamples(hitRates), summarizeSamples(byteHitRates))
+}
+
+// one line per seed, and the summary at last
+func (s *MultiSeedSimulator) GetStatStrings() []string {
+	return append(s.SweepSimulator.GetStatStrings(), s.StatString())
+}
+
+// "Seeds": 10 (seeds are 0, 1, ..., 9) or [1, 2, 3], "Simulator": definition of SimpleCacheSimulator
+func BuildMultiSeedSimulator(json interface{}) (*MultiSeedSimulator, error) {
+	p := dproxy.New(json)
+
+	simType, err := p.M("Type").String()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if simType != "MultiSeedSimulator" {
+		return nil, fmt.Errorf("Unsupported simulator type: %s", simType)
+	}
+
+	seeds := []int64{}
+
+	if n, err := p.M("Seeds").Int64(); err == nil {
+		for i := int64(0); i < n; i++ {
+			seeds = append(seeds, i)
+		}
+	} else {
+		seedsPS := p.M("Seeds").ProxySet()
+		for i := 0; i < seedsPS.Len(); i++ {
+			seed, err := seedsPS.A(i).Int64()
+			if err != nil {
+				return nil, err
+			}
+
+			seeds = append(seeds, seed)
+		}
+	}
+
+	if len(seeds) < 2 {
+		return nil, fmt.Errorf("MultiSeedSimulator needs 2 `Seeds` at least")
+	}
+
+	definition, err := p.M("Simulator").Value()
+	if err != nil {
+		return nil, err
+	}
+
+	// "Seed" of caches has priority over the seed of the simulator, every run would be the same
+	if cacheDefinition, err := p.M("Simulator").M("Cache").Value(); err == nil && definitionHasKey(cacheDefinition, "Seed") {
+		return nil, fmt.Errorf("`Seed` of caches is not supported by MultiSeedSimulator, use `Seeds`")
+	}
+
+	s := &MultiSeedSimulator{
+		SweepSimulator: &SweepSimulator{
+			batch: make([]*cache.Packet, 0, sweepBatchSize),
+		},
+		Seeds: seeds,
+	}
+
+	for _, seed := range seeds {
+		d := deepCopyDefinition(definition)
+		if err := setDefinitionValue(d, "Seed", float64(seed)); err != nil {
+			return nil, err
+		}
+
+		sim, err := BuildSimpleCacheSimulator(d)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Simulators = append(s.Simulators, sim)
+	}
+
+	return s, nil
+}
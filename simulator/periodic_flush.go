@@ -0,0 +1,49 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// PeriodicFlush clears whole cache every Packets packets or Seconds seconds of Packet.Time
+// (e.g. flow table reset of a switch after reconnection to the controller)
+type PeriodicFlush struct {
+	Packets int     // interval in packets, 0 if it is specified by time
+	Seconds float64 // interval in seconds of Packet.Time
+	Flushed int     // number of times the cache was cleared
+
+	processed int
+	nextTime  float64
+}
+
+// Due returns whether the cache should be cleared before processing the packet.
+// The packet must be given in order, and only once.
+func (pf *PeriodicFlush) Due(p *cache.Packet) bool {
+	first := pf.processed == 0
+	pf.processed += 1
+
+	if pf.Packets != 0 {
+		return !first && (pf.processed-1)%pf.Packets == 0
+	}
+
+	if first {
+		pf.nextTime = p.Time + pf.Seconds
+		return false
+	}
+
+	if p.Time < pf.nextTime {
+		return false
+	}
+
+	// flush only once even if some intervals have no packet
+	for pf.nextTime <= p.Time {
+		pf.nextTime += pf.Seconds
+	}
+
+	return true
+}
+
+func (pf *PeriodicFlush) StatString() string {
+	return fmt.Sprintf("{\"Packets\": %d, \"Seconds\": %v, \"Flushed\": %d}", pf.Packets, pf.Seconds, pf.Flushed)
+}
@@ -2,6 +2,8 @@ package simulator
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/koron/go-dproxy"
 	"github.com/kyontan/cache_simulator/cache"
@@ -9,28 +11,135 @@ import (
 
 type SimpleCacheSimulator struct {
 	cache.Cache
-	Stat CacheSimulatorStat
+	Stat         CacheSimulatorStat
+	KeyExtractor *cache.FlowKeyExtractor
+	Oracle       *cache.OPTOracle // not nil if the cache needs a pre-pass over the trace
+	SlowPath     *SlowPath        // not nil if misses are installed by slow path
+	Window       *WindowStat      // not nil if statistics of each window are written
+	WarmUp       *WarmUp          // not nil if packets at the beginning are excluded from Stat
+	Flush        *PeriodicFlush   // not nil if the cache is cleared periodically
+
+	// not nil if entries are invalidated by events given in a side file
+	InvalidationEvents *InvalidationEvents
+
+	// not nil if misses are classified by the simulator (MultiLayerCache classifies them by itself)
+	MissClassifier *cache.MissClassifier
+
+	PrintInterval int // print stat every PrintInterval packets, 0 if never
+
+	keys     map[cache.FiveTuple]struct{}
+	nextSeed int64 // seed which is not given to caches of the simulator
 }
 
 func (sim *SimpleCacheSimulator) Process(p *cache.Packet) bool {
+	f := sim.KeyExtractor.Extract(p)
+
+	if sim.Oracle != nil {
+		sim.Oracle.Advance(f)
+	}
+
+	if sim.Flush != nil && sim.Flush.Due(p) {
+		sim.Cache.Clear()
+		sim.Flush.Flushed += 1
+
+		if sim.MissClassifier != nil {
+			sim.MissClassifier.Clear()
+		}
+	}
+
+	if sim.InvalidationEvents != nil {
+		invalidated := sim.InvalidationEvents.Replay(p.Time, sim.Cache, sim.KeyExtractor)
+
+		if sim.MissClassifier != nil {
+			for _, key := range invalidated {
+				sim.MissClassifier.Invalidate(key)
+			}
+		}
+	}
+
+	warmingUp := false
+
+	if sim.WarmUp != nil && !sim.WarmUp.Ended() {
+		warmingUp = sim.WarmUp.InWarmUp(p)
+
+		if !warmingUp {
+			// the first measured packet, drop statistics of cache counted in warm-up
+			sim.resetStat(p.Time)
+		}
+	}
+
+	if _, seen := sim.keys[*f]; !seen && !warmingUp {
+		sim.keys[*f] = struct{}{}
+		sim.Stat.UniqueKeys += 1
+	}
+
+	var layerCountsBefore layerCounts
+	if sim.Window != nil && !warmingUp {
+		layerCountsBefore = multiLayerCounts(sim.Cache)
+	}
+
+	// prefetches on observation may evict entries
+	evicted := len(cache.ObservePacket(sim.Cache, p, f))
+
+	if sim.SlowPath != nil {
+		evicted += sim.SlowPath.Complete(p.Time, sim.Cache)
+	}
+
 	// find cache
-	cached := cache.AccessCache(sim.Cache, p)
+	cached, _ := sim.Cache.IsCachedWithFiveTuple(f, true)
 
-	if cached {
-		sim.Stat.Hit += 1
-	} else {
-		// replace cache entry if not hit
-		sim.Cache.CacheFiveTuple(p.FiveTuple())
+	if sim.MissClassifier != nil {
+		sim.MissClassifier.Record(f, cached)
+	}
+
+	if !cached {
+		if sim.SlowPath != nil {
+			// entry will be installed when slow path completes the setup
+			sim.SlowPath.Request(f, p.Time)
+		} else {
+			// replace cache entry if not hit
+			evicted += len(sim.Cache.CacheFiveTuple(f))
+		}
 	}
 
 	// fmt.Println(falc.Cache)
 	// fmt.Println(falc.Age)
 
-	sim.Stat.Processed += 1
+	if warmingUp {
+		sim.WarmUp.Stat.count(p, cached)
+	} else {
+		sim.Stat.Count(p, cached)
+	}
+
+	if sim.Window != nil && !warmingUp {
+		sim.Window.Record(sim.Cache, p, f, cached, evicted, layerCountsBefore)
+	}
 
 	return cached
 }
 
+// resetStat resets statistics kept by the cache, the miss classifier and the slow path
+func (sim *SimpleCacheSimulator) resetStat(now float64) {
+	cache.ResetStat(sim.Cache)
+
+	if sim.MissClassifier != nil {
+		sim.MissClassifier.ResetStat()
+	}
+
+	if sim.SlowPath != nil {
+		sim.SlowPath.ResetStat(now)
+	}
+}
+
+// Finish is called after all packets are processed
+func (sim *SimpleCacheSimulator) Finish() error {
+	if sim.Window != nil {
+		return sim.Window.Close(sim.Cache)
+	}
+
+	return nil
+}
+
 func (sim *SimpleCacheSimulator) GetStat() CacheSimulatorStat {
 	return sim.Stat
 }
@@ -42,12 +151,34 @@ func (sim *SimpleCacheSimulator) GetStatString() string {
 
 	statDetail := sim.Cache.StatString()
 
+	if sim.MissClassifier != nil {
+		statDetail = appendJSONField(statDetail, "MissClassification", sim.MissClassifier.StatString())
+	}
+
 	if statDetail == "" {
-		stat += ", \"StatDetail\": null}"
+		stat += ", \"StatDetail\": null"
 	} else {
-		stat += ", \"StatDetail\": " + statDetail + "}"
+		stat += ", \"StatDetail\": " + statDetail
+	}
+
+	if sim.SlowPath != nil {
+		stat += ", \"SlowPath\": " + sim.SlowPath.StatString()
+	}
+
+	if sim.WarmUp != nil {
+		stat += ", \"WarmUp\": " + sim.WarmUp.StatString()
+	}
+
+	if sim.Flush != nil {
+		stat += ", \"Flush\": " + sim.Flush.StatString()
 	}
 
+	if sim.InvalidationEvents != nil {
+		stat += ", \"InvalidationEvents\": " + sim.InvalidationEvents.StatString()
+	}
+
+	stat += "}"
+
 	return stat
 }
 
@@ -57,10 +188,373 @@ func NewCacheSimulatorStat(description, parameter string) CacheSimulatorStat {
 		Parameter: parameter,
 		Processed: 0,
 		Hit:       0,
+		ByProto:   map[string]TrafficStat{},
+		ByDstPort: map[string]TrafficStat{},
+	}
+}
+
+// "Key": {"Fields": ["Proto", "SrcIP", "DstIP", "SrcPort", "DstPort"], "DstPrefixLen": 24, "Bidirectional": true}
+func buildFlowKeyExtractor(p dproxy.Proxy) (*cache.FlowKeyExtractor, error) {
+	e := cache.NewFiveTupleKeyExtractor()
+
+	if _, err := p.Value(); isNotFound(err) {
+		return e, nil
+	}
+
+	fields, err := p.M("Fields").Array()
+	if err == nil {
+		e.Fields = []cache.FlowKeyField{}
+
+		for _, field := range fields {
+			fieldStr, ok := field.(string)
+			if !ok {
+				return nil, fmt.Errorf("`Fields` must be array of string: %v", field)
+			}
+
+			f, err := cache.StringToFlowKeyField(fieldStr)
+			if err != nil {
+				return nil, err
+			}
+
+			e.Fields = append(e.Fields, f)
+		}
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	prefixLens := []struct {
+		key string
+		max uint
+		v   *uint
+	}{
+		{"SrcPrefixLen", 32, &e.SrcPrefixLen},
+		{"DstPrefixLen", 32, &e.DstPrefixLen},
+		{"SrcPrefixLen6", 128, &e.SrcPrefixLen6},
+		{"DstPrefixLen6", 128, &e.DstPrefixLen6},
+	}
+
+	for _, prefixLen := range prefixLens {
+		v, err := optionalInt64(p.M(prefixLen.key), int64(*prefixLen.v))
+		if err != nil {
+			return nil, err
+		}
+
+		if v < 0 || int64(prefixLen.max) < v {
+			return nil, fmt.Errorf("`%s` must be in [0, %d]: %d", prefixLen.key, prefixLen.max, v)
+		}
+
+		*prefixLen.v = uint(v)
+	}
+
+	e.Bidirectional, err = optionalBool(p.M("Bidirectional"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func buildWindowStat(p dproxy.Proxy) (*WindowStat, error) {
+	packets, err := optionalInt64(p.M("Packets"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds, err := optionalFloat64(p.M("Seconds"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if (packets == 0) == (seconds == 0) {
+		return nil, fmt.Errorf("`Window` needs either positive `Packets` or `Seconds`")
+	}
+
+	if packets < 0 || seconds < 0 {
+		return nil, fmt.Errorf("`Window` length must be positive")
+	}
+
+	format, err := optionalString(p.M("Format"), "jsonl")
+	if err != nil {
+		return nil, err
+	}
+
+	if format != "jsonl" && format != "csv" {
+		return nil, fmt.Errorf("Unsupported window format: %s", format)
+	}
+
+	output, err := p.M("Output").String()
+	if err != nil {
+		return nil, err
 	}
+
+	fp, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWindowStat(int(packets), seconds, format, fp), nil
 }
 
-func buildCache(p dproxy.Proxy) (cache.Cache, error) {
+func buildWarmUp(p dproxy.Proxy) (*WarmUp, error) {
+	packets, err := optionalInt64(p.M("Packets"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds, err := optionalFloat64(p.M("Seconds"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if (packets == 0) == (seconds == 0) {
+		return nil, fmt.Errorf("`WarmUp` needs either positive `Packets` or `Seconds`")
+	}
+
+	if packets < 0 || seconds < 0 {
+		return nil, fmt.Errorf("`WarmUp` length must be positive")
+	}
+
+	return &WarmUp{
+		Packets: int(packets),
+		Seconds: seconds,
+	}, nil
+}
+
+// buildLFUAging returns nil if `Aging` of the cache is not given
+func buildLFUAging(p dproxy.Proxy) (*cache.LFUAging, error) {
+	p = p.M("Aging")
+
+	if _, err := p.Value(); isNotFound(err) {
+		return nil, nil
+	}
+
+	accesses, err := optionalInt64(p.M("Accesses"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds, err := optionalFloat64(p.M("Seconds"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if (accesses == 0) == (seconds == 0) {
+		return nil, fmt.Errorf("`Aging` needs either positive `Accesses` or `Seconds`")
+	}
+
+	if accesses < 0 || seconds < 0 {
+		return nil, fmt.Errorf("`Aging` interval must be positive")
+	}
+
+	return cache.NewLFUAging(uint(accesses), seconds), nil
+}
+
+func buildPeriodicFlush(p dproxy.Proxy) (*PeriodicFlush, error) {
+	packets, err := optionalInt64(p.M("Packets"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds, err := optionalFloat64(p.M("Seconds"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if (packets == 0) == (seconds == 0) {
+		return nil, fmt.Errorf("`Flush` needs either positive `Packets` or `Seconds`")
+	}
+
+	if packets < 0 || seconds < 0 {
+		return nil, fmt.Errorf("`Flush` interval must be positive")
+	}
+
+	return &PeriodicFlush{
+		Packets: int(packets),
+		Seconds: seconds,
+	}, nil
+}
+
+// cacheSizeFromDefinition returns "Size" of the cache, or of its "InnerCache".
+// Size of MultiLayerCache is the number of FiveTuples it can hold:
+// sizes of layers are summed under WriteBackExclusive, otherwise the larger layer holds the other.
+func cacheSizeFromDefinition(p dproxy.Proxy) (uint, error) {
+	size, err := p.M("Size").Int64()
+
+	if isNotFound(err) {
+		if _, innerErr := p.M("InnerCache").Value(); innerErr == nil {
+			innerSize, err := cacheSizeFromDefinition(p.M("InnerCache"))
+			if err != nil {
+				return 0, err
+			}
+
+			if cacheType, _ := p.M("Type").String(); cacheType == "CacheWithTinyLFUAdmission" {
+				// the window is not carved out of InnerCache
+				windowSize, err := tinyLFUWindowSize(p, innerSize)
+				if err != nil {
+					return 0, err
+				}
+
+				return innerSize + uint(windowSize), nil
+			}
+
+			return innerSize, nil
+		}
+
+		if cacheType, _ := p.M("Type").String(); cacheType == "MultiLayerCache" {
+			return multiLayerCacheSizeFromDefinition(p)
+		}
+	}
+
+	if err != nil {
+		cacheType, _ := p.M("Type").String()
+		return 0, fmt.Errorf("can't find size of %s: %v", cacheType, err)
+	}
+
+	return uint(size), nil
+}
+
+// tinyLFUWindowSize returns "WindowSize" of CacheWithTinyLFUAdmission,
+// 1% of InnerCache by default as W-TinyLFU paper
+func tinyLFUWindowSize(p dproxy.Proxy, innerSize uint) (int64, error) {
+	return optionalInt64(p.M("WindowSize"), int64(innerSize/100))
+}
+
+func multiLayerCacheSizeFromDefinition(p dproxy.Proxy) (uint, error) {
+	cacheLayersPS := p.M("CacheLayers").ProxySet()
+	cachePoliciesPS := p.M("CachePolicies").ProxySet()
+
+	if cacheLayersPS.Len() == 0 || cachePoliciesPS.Len() != cacheLayersPS.Len()-1 {
+		return 0, fmt.Errorf("can't find size of MultiLayerCache: invalid `CacheLayers` or `CachePolicies`")
+	}
+
+	size, err := cacheSizeFromDefinition(cacheLayersPS.A(0))
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 1; i < cacheLayersPS.Len(); i++ {
+		layerSize, err := cacheSizeFromDefinition(cacheLayersPS.A(i))
+		if err != nil {
+			return 0, err
+		}
+
+		policy, err := cachePoliciesPS.A(i - 1).String()
+		if err != nil {
+			return 0, err
+		}
+
+		if policy == "WriteBackExclusive" {
+			size += layerSize
+		} else if size < layerSize {
+			size = layerSize
+		}
+	}
+
+	return size, nil
+}
+
+type cacheBuildContext struct {
+	oracle       *cache.OPTOracle
+	keyExtractor *cache.FlowKeyExtractor // key of the simulator
+	seed         int64                   // seed given to the next cache which uses random numbers
+}
+
+// cacheSeed returns "Seed" of the cache if specified, or the seed of the simulator.
+// Seed of the simulator is incremented for each cache, so caches in a simulator don't share random sequence.
+func (ctx *cacheBuildContext) cacheSeed(p dproxy.Proxy) (int64, error) {
+	seed, err := p.M("Seed").Int64()
+	if err == nil {
+		return seed, nil
+	} else if !isNotFound(err) {
+		return 0, err
+	}
+
+	seed = ctx.seed
+	ctx.seed += 1
+
+	return seed, nil
+}
+
+func buildPrefetcher(p dproxy.Proxy, ctx *cacheBuildContext) (cache.Prefetcher, error) {
+	prefetcherType, err := p.M("Type").String()
+	if err != nil {
+		return nil, err
+	}
+
+	switch prefetcherType {
+	case "ReverseFlow":
+		protos := []cache.IPProtocol{cache.IP_TCP}
+
+		if _, err := p.M("Protos").Value(); !isNotFound(err) {
+			protosPS := p.M("Protos").ProxySet()
+			protos = []cache.IPProtocol{}
+
+			for i := 0; i < protosPS.Len(); i++ {
+				protoStr, err := protosPS.A(i).String()
+				if err != nil {
+					return nil, err
+				}
+
+				if protoStr != "tcp" && protoStr != "udp" {
+					return nil, fmt.Errorf("Unsupported protocol of ReverseFlow: %s", protoStr)
+				}
+
+				protos = append(protos, cache.StrToIPProtocol(protoStr))
+			}
+		}
+
+		return &cache.ReverseFlowPrefetcher{Protos: protos}, nil
+	case "DNS":
+		proto, err := optionalString(p.M("Proto"), "tcp")
+		if err != nil {
+			return nil, err
+		}
+
+		if proto != "tcp" && proto != "udp" {
+			return nil, fmt.Errorf("Unsupported protocol of DNS prefetcher: %s", proto)
+		}
+
+		dstPort, err := optionalInt64(p.M("DstPort"), 443)
+		if err != nil {
+			return nil, err
+		}
+
+		if dstPort < 0 || 0xffff < dstPort {
+			return nil, fmt.Errorf("Invalid DstPort: %d", dstPort)
+		}
+
+		// source port of the prefetched flow is unknown, so the prefetched key would never be referred
+		if ctx.keyExtractor.HasField(cache.FlowKeySrcPort) {
+			return nil, fmt.Errorf("DNS prefetcher needs `Key` without SrcPort")
+		}
+
+		return &cache.DNSPrefetcher{
+			Proto:        proto,
+			DstPort:      uint16(dstPort),
+			KeyExtractor: ctx.keyExtractor,
+		}, nil
+	case "Markov":
+		candidates, err := optionalInt64(p.M("Candidates"), 1)
+		if err != nil {
+			return nil, err
+		}
+
+		maxSuccessors, err := optionalInt64(p.M("MaxSuccessors"), 4)
+		if err != nil {
+			return nil, err
+		}
+
+		if candidates <= 0 || maxSuccessors < candidates {
+			return nil, fmt.Errorf("`Candidates` must be positive, and `MaxSuccessors` must not be less than `Candidates`")
+		}
+
+		return cache.NewMarkovPrefetcher(uint(candidates), uint(maxSuccessors)), nil
+	default:
+		return nil, fmt.Errorf("Unknown prefetcher: %s", prefetcherType)
+	}
+}
+
+func buildCache(p dproxy.Proxy, ctx *cacheBuildContext) (cache.Cache, error) {
 	cache_type, err := p.M("Type").String()
 
 	if err != nil {
@@ -71,14 +565,178 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 
 	switch cache_type {
 	case "CacheWithLookAhead":
-		innerCache, err := buildCache(p.M("InnerCache"))
+		innerCache, err := buildCache(p.M("InnerCache"), ctx)
+		if err != nil {
+			return c, err
+		}
+
+		prefetchers := []cache.Prefetcher{}
+
+		if _, err := p.M("Prefetchers").Value(); isNotFound(err) {
+			// reverse flow of TCP as before prefetchers are configurable
+			prefetchers = append(prefetchers, &cache.ReverseFlowPrefetcher{Protos: []cache.IPProtocol{cache.IP_TCP}})
+		} else {
+			prefetchersPS := p.M("Prefetchers").ProxySet()
+
+			for i := 0; i < prefetchersPS.Len(); i++ {
+				prefetcher, err := buildPrefetcher(prefetchersPS.A(i), ctx)
+				if err != nil {
+					return c, err
+				}
+
+				prefetchers = append(prefetchers, prefetcher)
+			}
+		}
+
+		c = cache.NewCacheWithLookAhead(innerCache, prefetchers)
+	case "CacheWithTimeout":
+		innerCache, err := buildCache(p.M("InnerCache"), ctx)
+		if err != nil {
+			return c, err
+		}
+
+		idleTimeout, err := optionalFloat64(p.M("IdleTimeout"), 0)
+		if err != nil {
+			return c, err
+		}
+
+		hardTimeout, err := optionalFloat64(p.M("HardTimeout"), 0)
+		if err != nil {
+			return c, err
+		}
+
+		// expired entries are found only by lookups if 0
+		sweepInterval, err := optionalFloat64(p.M("SweepInterval"), 0)
+		if err != nil {
+			return c, err
+		}
+
+		if idleTimeout < 0 || hardTimeout < 0 || sweepInterval < 0 {
+			return c, fmt.Errorf("timeouts and `SweepInterval` must be positive")
+		}
+
+		if idleTimeout == 0 && hardTimeout == 0 {
+			return c, fmt.Errorf("CacheWithTimeout needs `IdleTimeout` or `HardTimeout`")
+		}
+
+		c = cache.NewCacheWithTimeout(innerCache, idleTimeout, hardTimeout, sweepInterval)
+	case "CacheWithThresholdAdmission":
+		innerCache, err := buildCache(p.M("InnerCache"), ctx)
+		if err != nil {
+			return c, err
+		}
+
+		packets, err := optionalInt64(p.M("Packets"), 0)
+		if err != nil {
+			return c, err
+		}
+
+		bytes, err := optionalInt64(p.M("Bytes"), 0)
+		if err != nil {
+			return c, err
+		}
+
+		// probability to sample each byte
+		sampleProbability, err := optionalFloat64(p.M("SampleAndHold"), 0)
+		if err != nil {
+			return c, err
+		}
+
+		if packets < 0 || bytes < 0 || sampleProbability < 0 || 1 < sampleProbability {
+			return c, fmt.Errorf("`Packets` and `Bytes` must be positive, `SampleAndHold` must be in [0, 1]")
+		}
+
+		if packets == 0 && bytes == 0 && sampleProbability == 0 {
+			return c, fmt.Errorf("CacheWithThresholdAdmission needs `Packets`, `Bytes` or `SampleAndHold`")
+		}
+
+		var seed int64
+		if sampleProbability != 0 {
+			seed, err = ctx.cacheSeed(p)
+			if err != nil {
+				return c, err
+			}
+		}
+
+		counterType, err := optionalString(p.M("Counter"), "Exact")
+		if err != nil {
+			return c, err
+		}
+
+		var counter cache.TrafficCounter
+
+		switch counterType {
+		case "Exact":
+			counter = cache.NewExactTrafficCounter()
+		case "Sketch":
+			width, err := p.M("SketchWidth").Int64()
+			if err != nil {
+				return c, err
+			}
+
+			depth, err := optionalInt64(p.M("SketchDepth"), 4)
+			if err != nil {
+				return c, err
+			}
+
+			if width <= 0 || depth <= 0 {
+				return c, fmt.Errorf("`SketchWidth` and `SketchDepth` must be positive")
+			}
+
+			counter = cache.NewSketchTrafficCounter(uint(width), uint(depth))
+		default:
+			return c, fmt.Errorf("Unknown counter: %s", counterType)
+		}
+
+		c = cache.NewCacheWithThresholdAdmission(innerCache, counter, uint(packets), uint(bytes), sampleProbability, seed)
+	case "CacheWithTinyLFUAdmission":
+		innerCache, err := buildCache(p.M("InnerCache"), ctx)
+		if err != nil {
+			return c, err
+		}
+
+		if _, ok := innerCache.(cache.VictimPeeker); !ok {
+			return c, fmt.Errorf("%s is not supported as InnerCache of CacheWithTinyLFUAdmission", innerCache.Description())
+		}
+
+		innerSize, err := cacheSizeFromDefinition(p.M("InnerCache"))
+		if err != nil {
+			return c, err
+		}
+
+		windowSize, err := tinyLFUWindowSize(p, innerSize)
+		if err != nil {
+			return c, err
+		}
+
+		sketchWidth, err := optionalInt64(p.M("SketchWidth"), int64(innerSize)+windowSize)
 		if err != nil {
 			return c, err
 		}
 
-		c = &cache.CacheWithLookAhead{
-			InnerCache: innerCache,
+		sketchDepth, err := optionalInt64(p.M("SketchDepth"), 4)
+		if err != nil {
+			return c, err
 		}
+
+		sampleSize, err := optionalInt64(p.M("SampleSize"), 10*(int64(innerSize)+windowSize))
+		if err != nil {
+			return c, err
+		}
+
+		doorkeeperSize, err := optionalInt64(p.M("DoorkeeperSize"), 8*sampleSize)
+		if err != nil {
+			return c, err
+		}
+
+		if windowSize < 0 || sketchWidth <= 0 || sketchDepth <= 0 || sampleSize <= 0 || doorkeeperSize <= 0 {
+			return c, fmt.Errorf("parameters of CacheWithTinyLFUAdmission must be positive")
+		}
+
+		tinyLFU := cache.NewCacheWithTinyLFUAdmission(innerCache, uint(windowSize), uint(sketchWidth), uint(sketchDepth), uint(sampleSize), uint(doorkeeperSize))
+		tinyLFU.Size = innerSize + uint(windowSize)
+
+		c = tinyLFU
 	case "FullAssociativeLRUCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
@@ -92,21 +750,38 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 			return c, err
 		}
 
-		c = cache.NewFullAssociativeTreePLRUCache(uint(size))
+		maskedLeaves, err := optionalBool(p.M("MaskedLeaves"), false)
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewFullAssociativeTreePLRUCache(uint(size), maskedLeaves)
 	case "FullAssociativeLFUCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
 			return c, err
 		}
 
-		c = cache.NewFullAssociativeLFUCache(uint(size))
+		lfu := cache.NewFullAssociativeLFUCache(uint(size))
+
+		lfu.Aging, err = buildLFUAging(p)
+		if err != nil {
+			return c, err
+		}
+
+		c = lfu
 	case "FullAssociativeRandomCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
 			return c, err
 		}
 
-		c = cache.NewFullAssociativeRandomCache(uint(size))
+		seed, err := ctx.cacheSeed(p)
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewFullAssociativeRandomCache(uint(size), seed)
 	case "FullAssociativeFIFOCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
@@ -114,6 +789,20 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 		}
 
 		c = cache.NewFullAssociativeFIFOCache(uint(size))
+	case "FullAssociativeARCCache":
+		size, err := p.M("Size").Int64()
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewFullAssociativeARCCache(uint(size))
+	case "FullAssociativeOPTCache":
+		size, err := p.M("Size").Int64()
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewFullAssociativeOPTCache(uint(size), ctx.oracle)
 	case "NWaySetAssociativeLRUCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
@@ -137,7 +826,12 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 			return c, err
 		}
 
-		c = cache.NewNWaySetAssociativeTreePLRUCache(uint(size), uint(way))
+		maskedLeaves, err := optionalBool(p.M("MaskedLeaves"), false)
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewNWaySetAssociativeTreePLRUCache(uint(size), uint(way), maskedLeaves)
 	case "NWaySetAssociativeLFUCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
@@ -149,7 +843,14 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 			return c, err
 		}
 
-		c = cache.NewNWaySetAssociativeLFUCache(uint(size), uint(way))
+		lfu := cache.NewNWaySetAssociativeLFUCache(uint(size), uint(way))
+
+		lfu.Aging, err = buildLFUAging(p)
+		if err != nil {
+			return c, err
+		}
+
+		c = lfu
 	case "NWaySetAssociativeRandomCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
@@ -161,7 +862,12 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 			return c, err
 		}
 
-		c = cache.NewNWaySetAssociativeRandomCache(uint(size), uint(way))
+		seed, err := ctx.cacheSeed(p)
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewNWaySetAssociativeRandomCache(uint(size), uint(way), seed)
 	case "NWaySetAssociativeFIFOCache":
 		size, err := p.M("Size").Int64()
 		if err != nil {
@@ -174,6 +880,52 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 		}
 
 		c = cache.NewNWaySetAssociativeFIFOCache(uint(size), uint(way))
+	case "NWaySetAssociativeARCCache":
+		size, err := p.M("Size").Int64()
+		if err != nil {
+			return c, err
+		}
+
+		way, err := p.M("Way").Int64()
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewNWaySetAssociativeARCCache(uint(size), uint(way))
+	case "SkewedAssociativeCache":
+		size, err := p.M("Size").Int64()
+		if err != nil {
+			return c, err
+		}
+
+		way, err := p.M("Way").Int64()
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewSkewedAssociativeCache(uint(size), uint(way))
+	case "CuckooHashCache":
+		size, err := p.M("Size").Int64()
+		if err != nil {
+			return c, err
+		}
+
+		d, err := optionalInt64(p.M("D"), 2)
+		if err != nil {
+			return c, err
+		}
+
+		maxDisplacements, err := optionalInt64(p.M("MaxDisplacements"), 16)
+		if err != nil {
+			return c, err
+		}
+
+		stashSize, err := optionalInt64(p.M("StashSize"), 0)
+		if err != nil {
+			return c, err
+		}
+
+		c = cache.NewCuckooHashCache(uint(size), uint(d), uint(maxDisplacements), uint(stashSize))
 	case "MultiLayerCache":
 		cacheLayersPS := p.M("CacheLayers").ProxySet()
 		cachePoliciesPS := p.M("CachePolicies").ProxySet()
@@ -186,7 +938,7 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 
 		cacheLayers := make([]cache.Cache, cacheLayersLen)
 		for i := 0; i < cacheLayersLen; i++ {
-			cacheLayer, err := buildCache(cacheLayersPS.A(i))
+			cacheLayer, err := buildCache(cacheLayersPS.A(i), ctx)
 			if err != nil {
 				return c, err
 			}
@@ -210,10 +962,67 @@ func buildCache(p dproxy.Proxy) (cache.Cache, error) {
 			CacheReplacedByLayer: make([]uint, cacheLayersLen),
 			CacheHitByLayer:      make([]uint, cacheLayersLen),
 		}
+
+		// "Latencies": [1, 10], "MissLatency": 1000
+		if _, err := p.M("Latencies").Value(); err == nil {
+			latenciesPS := p.M("Latencies").ProxySet()
+			if latenciesPS.Len() != cacheLayersLen {
+				return c, fmt.Errorf("`Latencies` (%d items) must have `CacheLayers` length (%d) items", latenciesPS.Len(), cacheLayersLen)
+			}
+
+			latencies := make([]float64, cacheLayersLen)
+			for i := 0; i < cacheLayersLen; i++ {
+				latencies[i], err = latenciesPS.A(i).Float64()
+				if err != nil {
+					return c, err
+				}
+			}
+
+			missLatency, err := optionalFloat64(p.M("MissLatency"), 0)
+			if err != nil {
+				return c, err
+			}
+
+			c.(*cache.MultiLayerCache).Latency = cache.NewLatencyModel(latencies, missLatency)
+		} else if !isNotFound(err) {
+			return c, err
+		}
 	default:
 		return nil, fmt.Errorf("Unsupported cache type: %s", cache_type)
 	}
 
+	// "Hash": "CRC32", "CRC16", "Toeplitz", "XORFold", "BitSelect", "FNV" or "xxHash"
+	if hashStr, err := p.M("Hash").String(); err == nil {
+		sa, ok := c.(cache.SetAssociativeCache)
+		if !ok {
+			return nil, fmt.Errorf("`Hash` is not supported by %s", cache_type)
+		}
+
+		hash, err := cache.StringToHashFunction(hashStr)
+		if err != nil {
+			return nil, err
+		}
+
+		sa.SetHashFunction(hash)
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	// "SetStat": true to print occupancy and accesses of sets, costs O(number of sets) on every print
+	setStat, err := optionalBool(p.M("SetStat"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if setStat {
+		sc, ok := c.(cache.SetStatCollector)
+		if !ok {
+			return nil, fmt.Errorf("`SetStat` is not supported by %s", cache_type)
+		}
+
+		sc.EnableSetStat()
+	}
+
 	return c, nil
 }
 
@@ -232,18 +1041,134 @@ func BuildSimpleCacheSimulator(json interface{}) (*SimpleCacheSimulator, error)
 
 	cacheProxy := p.M("Cache")
 
-	cache, err := buildCache(cacheProxy)
+	// seed of random caches without "Seed", random unless specified
+	seed, err := optionalInt64(p.M("Seed"), time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+
+	keyExtractor, err := buildFlowKeyExtractor(p.M("Key"))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &cacheBuildContext{
+		oracle:       cache.NewOPTOracle(),
+		keyExtractor: keyExtractor,
+		seed:         seed,
+	}
+
+	c, err := buildCache(cacheProxy, ctx)
 
 	if err != nil {
 		return nil, err
 	}
 
 	sim := &SimpleCacheSimulator{
-		Cache: cache,
+		Cache: c,
 		Stat: NewCacheSimulatorStat(
-			cache.Description(),
-			cache.ParameterString(),
+			c.Description(),
+			c.ParameterString(),
 		),
+		KeyExtractor: keyExtractor,
+		keys:         map[cache.FiveTuple]struct{}{},
+		nextSeed:     ctx.seed,
+	}
+
+	printInterval, err := optionalInt64(p.M("PrintInterval"), 1)
+	if err != nil {
+		return nil, err
+	}
+	sim.PrintInterval = int(printInterval)
+	sim.Stat.Key = keyExtractor.ParameterString()
+
+	classifyMisses, err := optionalBool(p.M("ClassifyMisses"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if classifyMisses {
+		if mlc, ok := c.(*cache.MultiLayerCache); ok {
+			cacheLayersPS := cacheProxy.M("CacheLayers").ProxySet()
+			mlc.MissClassifiers = make([]*cache.MissClassifier, len(mlc.CacheLayers))
+
+			for i := range mlc.CacheLayers {
+				size, err := cacheSizeFromDefinition(cacheLayersPS.A(i))
+				if err != nil {
+					return nil, err
+				}
+
+				mlc.MissClassifiers[i] = cache.NewMissClassifier(size)
+			}
+		} else {
+			size, err := cacheSizeFromDefinition(cacheProxy)
+			if err != nil {
+				return nil, err
+			}
+
+			sim.MissClassifier = cache.NewMissClassifier(size)
+		}
+	}
+
+	// "SlowPath": {"ServiceTime": 0.001, "QueueDepth": 64}
+	if _, err := p.M("SlowPath").Value(); err == nil {
+		serviceTime, err := p.M("SlowPath").M("ServiceTime").Float64()
+		if err != nil {
+			return nil, err
+		}
+
+		queueDepth, err := optionalInt64(p.M("SlowPath").M("QueueDepth"), 0)
+		if err != nil {
+			return nil, err
+		}
+
+		sim.SlowPath = NewSlowPath(serviceTime, int(queueDepth))
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	// "Window": {"Packets": 10000 or "Seconds": 1.0, "Format": "jsonl" or "csv", "Output": "window.jsonl"}
+	if _, err := p.M("Window").Value(); err == nil {
+		sim.Window, err = buildWindowStat(p.M("Window"))
+		if err != nil {
+			return nil, err
+		}
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	// "WarmUp": {"Packets": 100000} or {"Seconds": 10.0}
+	if _, err := p.M("WarmUp").Value(); err == nil {
+		sim.WarmUp, err = buildWarmUp(p.M("WarmUp"))
+		if err != nil {
+			return nil, err
+		}
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	// "Flush": {"Packets": 100000} or {"Seconds": 60.0}
+	if _, err := p.M("Flush").Value(); err == nil {
+		sim.Flush, err = buildPeriodicFlush(p.M("Flush"))
+		if err != nil {
+			return nil, err
+		}
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	// "InvalidationEvents": "deletions.csv", rows of [time] [proto] [srcIP] [dstIP] [srcPort] [dstPort]
+	if path, err := p.M("InvalidationEvents").String(); err == nil {
+		sim.InvalidationEvents, err = LoadInvalidationEvents(path)
+		if err != nil {
+			return nil, err
+		}
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	if ctx.oracle.HasCaches() {
+		sim.Oracle = ctx.oracle
 	}
 
 	return sim, nil
@@ -0,0 +1,165 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// SlowPath models flow setup on cache miss, e.g. switch CPU or SDN controller.
+// Requests are served one by one in FIFO order, and each takes ServiceTime.
+// The entry is installed into the cache when its request is completed.
+type SlowPath struct {
+	ServiceTime float64 // seconds to set up one flow
+	QueueDepth  int     // max number of requests waiting or in service, 0 if unlimited
+
+	Requests      int // flow setup requests
+	Installed     int
+	PendingMisses int // misses of packets arrived while the setup of its flow is in progress
+	Drops         int // packets dropped because queue is full
+	MaxQueueLen   int
+
+	queue          []slowPathRequest
+	pending        map[cache.FiveTuple]struct{}
+	lastCompletion float64
+
+	// time-weighted queue length
+	queueLenArea  float64
+	firstTime     float64
+	lastTime      float64
+	timeInitiated bool
+
+	setupLatencies []float64
+}
+
+type slowPathRequest struct {
+	FiveTuple  cache.FiveTuple
+	Arrival    float64
+	Completion float64
+}
+
+func (sp *SlowPath) advanceTime(now float64) {
+	if !sp.timeInitiated {
+		sp.firstTime = now
+		sp.lastTime = now
+		sp.timeInitiated = true
+	}
+
+	if sp.lastTime < now {
+		sp.queueLenArea += float64(len(sp.queue)) * (now - sp.lastTime)
+		sp.lastTime = now
+	}
+}
+
+// Complete installs entries whose setup completes until now, returns number of evicted entries
+func (sp *SlowPath) Complete(now float64, c cache.Cache) int {
+	evicted := 0
+
+	for len(sp.queue) != 0 && sp.queue[0].Completion <= now {
+		req := sp.queue[0]
+
+		sp.advanceTime(req.Completion)
+		sp.queue = sp.queue[1:]
+
+		evicted += len(c.CacheFiveTuple(&req.FiveTuple))
+		delete(sp.pending, req.FiveTuple)
+
+		sp.Installed += 1
+		sp.setupLatencies = append(sp.setupLatencies, req.Completion-req.Arrival)
+	}
+
+	sp.advanceTime(now)
+
+	return evicted
+}
+
+// Request is called on cache miss, returns false if the packet is dropped
+func (sp *SlowPath) Request(f *cache.FiveTuple, now float64) bool {
+	if _, pending := sp.pending[*f]; pending {
+		sp.PendingMisses += 1
+		return true
+	}
+
+	if sp.QueueDepth != 0 && sp.QueueDepth <= len(sp.queue) {
+		sp.Drops += 1
+		return false
+	}
+
+	start := math.Max(now, sp.lastCompletion)
+	req := slowPathRequest{
+		FiveTuple:  *f,
+		Arrival:    now,
+		Completion: start + sp.ServiceTime,
+	}
+
+	sp.lastCompletion = req.Completion
+	sp.queue = append(sp.queue, req)
+	sp.pending[*f] = struct{}{}
+	sp.Requests += 1
+
+	if sp.MaxQueueLen < len(sp.queue) {
+		sp.MaxQueueLen = len(sp.queue)
+	}
+
+	return true
+}
+
+// ResetStat drops statistics counted until now, requests in the queue are kept
+func (sp *SlowPath) ResetStat(now float64) {
+	sp.Requests = 0
+	sp.Installed = 0
+	sp.PendingMisses = 0
+	sp.Drops = 0
+	sp.MaxQueueLen = len(sp.queue)
+
+	sp.queueLenArea = 0
+	sp.firstTime = now
+	sp.lastTime = now
+	sp.timeInitiated = true
+
+	sp.setupLatencies = nil
+}
+
+func (sp *SlowPath) StatString() string {
+	meanQueueLen := 0.0
+	if sp.firstTime < sp.lastTime {
+		meanQueueLen = sp.queueLenArea / (sp.lastTime - sp.firstTime)
+	}
+
+	latencies := append([]float64{}, sp.setupLatencies...)
+	sort.Float64s(latencies)
+
+	percentile := func(p float64) float64 {
+		if len(latencies) == 0 {
+			return 0
+		}
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	sum := 0.0
+	for _, l := range latencies {
+		sum += l
+	}
+
+	meanLatency := 0.0
+	if len(latencies) != 0 {
+		meanLatency = sum / float64(len(latencies))
+	}
+
+	return fmt.Sprintf("{\"ServiceTime\": %v, \"QueueDepth\": %d, \"Requests\": %d, \"Installed\": %d, \"PendingMisses\": %d, \"Drops\": %d, "+
+		"\"MeanQueueLen\": %v, \"MaxQueueLen\": %d, "+
+		"\"SetupLatency\": {\"Mean\": %v, \"P50\": %v, \"P90\": %v, \"P99\": %v, \"Max\": %v}}",
+		sp.ServiceTime, sp.QueueDepth, sp.Requests, sp.Installed, sp.PendingMisses, sp.Drops,
+		meanQueueLen, sp.MaxQueueLen,
+		meanLatency, percentile(0.5), percentile(0.9), percentile(0.99), percentile(1))
+}
+
+func NewSlowPath(serviceTime float64, queueDepth int) *SlowPath {
+	return &SlowPath{
+		ServiceTime: serviceTime,
+		QueueDepth:  queueDepth,
+		pending:     map[cache.FiveTuple]struct{}{},
+	}
+}
@@ -0,0 +1,237 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/koron/go-dproxy"
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// StackDistanceAnalyzer computes LRU stack (reuse) distances in one pass (Mattson et al.),
+// which gives hit rate of FullAssociativeLRUCache for every size up to MaxSize.
+// If Way is given, it also gives hit rate of NWaySetAssociativeLRUCache with the Way
+// for every power-of-two number of sets up to MaxSize / Way.
+type StackDistanceAnalyzer struct {
+	MaxSize   uint
+	Way       uint               // 0 if set associative caches are not analyzed
+	Hash      cache.HashFunction // selects a set of set associative caches
+	Processed int
+	Cold      int   // first reference of each FiveTuple
+	Distances []int // Distances[d]: number of references with stack distance d (< MaxSize)
+
+	KeyExtractor    *cache.FlowKeyExtractor
+	SetAssociatives []*setAssociativeStackDistance
+
+	lastAccess map[cache.FiveTuple]int
+	accessed   []cache.FiveTuple // accessed[t]: FiveTuple referred at time t
+	tree       fenwickTree       // tree[t] == 1 if accessed[t] is the last access of the FiveTuple
+	now        int
+}
+
+// LRU stacks of each set truncated at depth Way
+type setAssociativeStackDistance struct {
+	NumSets   uint
+	Distances []int // Distances[d]: number of references with stack distance d in the set (< Way)
+
+	stacks [][]cache.FiveTuple
+}
+
+type fenwickTree []int
+
+func (t fenwickTree) add(i, v int) {
+	for i += 1; i <= len(t); i += i & -i {
+		t[i-1] += v
+	}
+}
+
+// sum of [0, i)
+func (t fenwickTree) prefixSum(i int) int {
+	sum := 0
+	for ; 0 < i; i -= i & -i {
+		sum += t[i-1]
+	}
+	return sum
+}
+
+func (sd *setAssociativeStackDistance) process(f *cache.FiveTuple, way uint, hash cache.HashFunction) {
+	setIdx := hash.SetIdx(f, sd.NumSets)
+	stack := sd.stacks[setIdx]
+
+	depth := len(stack)
+	for i, x := range stack {
+		if x == *f {
+			depth = i
+			break
+		}
+	}
+
+	if depth < len(stack) {
+		sd.Distances[depth] += 1
+	} else if len(stack) < int(way) {
+		stack = append(stack, cache.FiveTuple{})
+	} else {
+		depth = len(stack) - 1
+	}
+
+	copy(stack[1:depth+1], stack[0:depth])
+	stack[0] = *f
+	sd.stacks[setIdx] = stack
+}
+
+// renumber times of last accesses to 0, 1, ... when the tree is full
+func (a *StackDistanceAnalyzer) compact() {
+	active := []cache.FiveTuple{}
+	for t, f := range a.accessed[:a.now] {
+		if a.lastAccess[f] == t {
+			active = append(active, f)
+		}
+	}
+
+	capacity := 2 * (len(active) + 1024)
+	a.accessed = make([]cache.FiveTuple, capacity)
+	a.tree = make(fenwickTree, capacity)
+
+	for t, f := range active {
+		a.accessed[t] = f
+		a.lastAccess[f] = t
+		a.tree.add(t, 1)
+	}
+
+	a.now = len(active)
+}
+
+func (a *StackDistanceAnalyzer) Process(p *cache.Packet) {
+	f := a.KeyExtractor.Extract(p)
+
+	if a.now == len(a.accessed) {
+		a.compact()
+	}
+
+	if last, ok := a.lastAccess[*f]; ok {
+		distance := a.tree.prefixSum(a.now) - a.tree.prefixSum(last+1)
+		if distance < int(a.MaxSize) {
+			a.Distances[distance] += 1
+		}
+		a.tree.add(last, -1)
+	} else {
+		a.Cold += 1
+	}
+
+	a.lastAccess[*f] = a.now
+	a.accessed[a.now] = *f
+	a.tree.add(a.now, 1)
+	a.now += 1
+
+	for _, sd := range a.SetAssociatives {
+		sd.process(f, a.Way, a.Hash)
+	}
+
+	a.Processed += 1
+}
+
+func (a *StackDistanceAnalyzer) ParameterString() string {
+	return fmt.Sprintf("{\"Type\": \"StackDistanceAnalyzer\", \"MaxSize\": %d, \"Way\": %d, \"Hash\": \"%s\", \"Key\": %s}", a.MaxSize, a.Way, a.Hash, a.KeyExtractor.ParameterString())
+}
+
+func (a *StackDistanceAnalyzer) GetStatString() string {
+	str := fmt.Sprintf("{\"Type\": \"StackDistanceAnalyzer\", \"Parameter\": %s, \"Processed\": %v, \"Cold\": %v, ", a.ParameterString(), a.Processed, a.Cold)
+
+	str += "\"FullAssociativeLRUCache\": ["
+
+	hit := 0
+	for i, d := range a.Distances {
+		if i != 0 {
+			str += ", "
+		}
+
+		hit += d
+		str += fmt.Sprintf("{\"Size\": %d, \"Hit\": %d, \"HitRate\": %v}", i+1, hit, float64(hit)/float64(a.Processed))
+	}
+
+	str += "], "
+	str += "\"NWaySetAssociativeLRUCache\": ["
+
+	for i, sd := range a.SetAssociatives {
+		if i != 0 {
+			str += ", "
+		}
+
+		hit := 0
+		for _, d := range sd.Distances {
+			hit += d
+		}
+
+		str += fmt.Sprintf("{\"Size\": %d, \"Way\": %d, \"Hit\": %d, \"HitRate\": %v}", sd.NumSets*a.Way, a.Way, hit, float64(hit)/float64(a.Processed))
+	}
+
+	str += "]}"
+
+	return str
+}
+
+func NewStackDistanceAnalyzer(maxSize, way uint) *StackDistanceAnalyzer {
+	a := &StackDistanceAnalyzer{
+		MaxSize:      maxSize,
+		Way:          way,
+		Distances:    make([]int, maxSize),
+		KeyExtractor: cache.NewFiveTupleKeyExtractor(),
+		lastAccess:   map[cache.FiveTuple]int{},
+	}
+
+	if way != 0 {
+		for numSets := uint(1); numSets*way <= maxSize; numSets *= 2 {
+			a.SetAssociatives = append(a.SetAssociatives, &setAssociativeStackDistance{
+				NumSets:   numSets,
+				Distances: make([]int, way),
+				stacks:    make([][]cache.FiveTuple, numSets),
+			})
+		}
+	}
+
+	return a
+}
+
+func BuildStackDistanceAnalyzer(json interface{}) (*StackDistanceAnalyzer, error) {
+	p := dproxy.New(json)
+
+	analyzerType, err := p.M("Type").String()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if analyzerType != "StackDistanceAnalyzer" {
+		return nil, fmt.Errorf("Unsupported analyzer type: %s", analyzerType)
+	}
+
+	maxSize, err := p.M("MaxSize").Int64()
+	if err != nil {
+		return nil, err
+	}
+
+	way, err := optionalInt64(p.M("Way"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	hashStr, err := optionalString(p.M("Hash"), cache.HashCRC32.String())
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := cache.StringToHashFunction(hashStr)
+	if err != nil {
+		return nil, err
+	}
+
+	keyExtractor, err := buildFlowKeyExtractor(p.M("Key"))
+	if err != nil {
+		return nil, err
+	}
+
+	a := NewStackDistanceAnalyzer(uint(maxSize), uint(way))
+	a.KeyExtractor = keyExtractor
+	a.Hash = hash
+
+	return a, nil
+}
@@ -0,0 +1,92 @@
+package simulator
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// randomPackets returns packets of numFlows flows, popular flows are referred more often
+func randomPackets(n, numFlows int, seed int64) []*cache.Packet {
+	rng := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(numFlows-1))
+
+	packets := make([]*cache.Packet, n)
+	for i := range packets {
+		flow := int(zipf.Uint64())
+		packets[i] = &cache.Packet{
+			Time:    float64(i),
+			Len:     64,
+			Proto:   "tcp",
+			SrcIP:   net.IPv4(10, 0, byte(flow>>8), byte(flow)),
+			DstIP:   net.IPv4(192, 168, 0, 1),
+			SrcPort: uint16(1024 + flow),
+			DstPort: 80,
+		}
+	}
+
+	return packets
+}
+
+func lruHits(c cache.Cache, packets []*cache.Packet) int {
+	hits := 0
+
+	for _, p := range packets {
+		f := p.FiveTuple()
+		if hit, _ := c.IsCachedWithFiveTuple(f, true); hit {
+			hits += 1
+		} else {
+			c.CacheFiveTuple(f)
+		}
+	}
+
+	return hits
+}
+
+func TestStackDistanceAnalyzerMatchesLRUCaches(t *testing.T) {
+	const maxSize, way = 256, 4
+
+	// longer than the initial capacity of the tree, so that compact() runs several times
+	packets := randomPackets(20000, 2000, 1)
+
+	a := NewStackDistanceAnalyzer(maxSize, way)
+	for _, p := range packets {
+		a.Process(p)
+	}
+
+	if a.Processed != len(packets) {
+		t.Fatalf("Processed: %d, expected: %d", a.Processed, len(packets))
+	}
+
+	for _, size := range []uint{1, 2, 3, 16, 100, 256} {
+		expected := lruHits(cache.NewFullAssociativeLRUCache(size), packets)
+
+		hits := 0
+		for _, d := range a.Distances[:size] {
+			hits += d
+		}
+
+		if hits != expected {
+			t.Errorf("FullAssociativeLRUCache (Size: %d): hits by stack distance %d, expected: %d", size, hits, expected)
+		}
+	}
+
+	if len(a.SetAssociatives) != 7 {
+		t.Fatalf("number of analyzed set associative caches: %d, expected: 7", len(a.SetAssociatives))
+	}
+
+	for _, sd := range a.SetAssociatives {
+		expected := lruHits(cache.NewNWaySetAssociativeLRUCache(sd.NumSets*way, way), packets)
+
+		hits := 0
+		for _, d := range sd.Distances {
+			hits += d
+		}
+
+		if hits != expected {
+			t.Errorf("NWaySetAssociativeLRUCache (Sets: %d, Way: %d): hits by stack distance %d, expected: %d", sd.NumSets, way, hits, expected)
+		}
+	}
+}
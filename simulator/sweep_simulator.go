@@ -0,0 +1,280 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/koron/go-dproxy"
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+const sweepBatchSize = 4096
+
+// SweepSimulator runs many SimpleCacheSimulators on one trace.
+// Each simulator processes packets in its own goroutine, so results are same as running them one by one.
+type SweepSimulator struct {
+	Simulators []*SimpleCacheSimulator
+
+	batch    []*cache.Packet
+	channels []chan []*cache.Packet
+	wg       sync.WaitGroup
+}
+
+func (s *SweepSimulator) start() {
+	s.channels = make([]chan []*cache.Packet, len(s.Simulators))
+
+	for i, sim := range s.Simulators {
+		ch := make(chan []*cache.Packet, 4)
+		s.channels[i] = ch
+
+		s.wg.Add(1)
+		go func(sim *SimpleCacheSimulator, ch chan []*cache.Packet) {
+			defer s.wg.Done()
+
+			for batch := range ch {
+				for _, p := range batch {
+					sim.Process(p)
+				}
+			}
+		}(sim, ch)
+	}
+}
+
+func (s *SweepSimulator) flush() {
+	if len(s.batch) == 0 {
+		return
+	}
+
+	// batch is shared by all simulators, packets must not be modified
+	for _, ch := range s.channels {
+		ch <- s.batch
+	}
+
+	s.batch = make([]*cache.Packet, 0, sweepBatchSize)
+}
+
+func (s *SweepSimulator) Process(p *cache.Packet) {
+	if s.channels == nil {
+		s.start()
+	}
+
+	s.batch = append(s.batch, p)
+
+	if len(s.batch) == sweepBatchSize {
+		s.flush()
+	}
+}
+
+// Wait waits until all simulators process all packets given to Process
+func (s *SweepSimulator) Wait() {
+	if s.channels != nil {
+		s.flush()
+
+		for _, ch := range s.channels {
+			close(ch)
+		}
+
+		s.wg.Wait()
+		s.channels = nil
+	}
+
+	for _, sim := range s.Simulators {
+		if err := sim.Finish(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// one line per simulator
+func (s *SweepSimulator) GetStatStrings() []string {
+	stats := make([]string, len(s.Simulators))
+
+	for i, sim := range s.Simulators {
+		stats[i] = sim.GetStatString()
+	}
+
+	return stats
+}
+
+func deepCopyDefinition(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			m[k] = deepCopyDefinition(x)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, x := range v {
+			a[i] = deepCopyDefinition(x)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+// definitionHasKey returns whether any object in the definition has the key
+func definitionHasKey(v interface{}, key string) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			if k == key || definitionHasKey(x, key) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, x := range v {
+			if definitionHasKey(x, key) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// setDefinitionValue sets value at dot separated path (e.g. "Cache.CacheLayers.0.Size")
+func setDefinitionValue(definition interface{}, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	v := definition
+
+	for i, key := range keys {
+		last := i == len(keys)-1
+
+		switch node := v.(type) {
+		case map[string]interface{}:
+			if last {
+				node[key] = value
+				return nil
+			}
+
+			next, ok := node[key]
+			if !ok {
+				next = map[string]interface{}{}
+				node[key] = next
+			}
+			v = next
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || len(node) <= idx {
+				return fmt.Errorf("invalid index `%s` in path `%s`", key, path)
+			}
+
+			if last {
+				node[idx] = value
+				return nil
+			}
+			v = node[idx]
+		default:
+			return fmt.Errorf("`%s` in path `%s` is neither object nor array", strings.Join(keys[:i], "."), path)
+		}
+	}
+
+	return nil
+}
+
+// "Grid": {"Template": {...}, "Parameters": {"Cache.Size": [256, 1024], "Cache.Way": [4, 8]}}
+// gives cartesian product of parameters, the last key (sorted by name) varies fastest
+func expandGridDefinitions(p dproxy.Proxy) ([]interface{}, error) {
+	template, err := p.M("Template").Value()
+	if err != nil {
+		return nil, err
+	}
+
+	parameters, err := p.M("Parameters").Map()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(parameters))
+	for path := range parameters {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	definitions := []interface{}{deepCopyDefinition(template)}
+
+	for _, path := range paths {
+		values, ok := parameters[path].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("values of grid parameter `%s` must be array", path)
+		}
+
+		expanded := make([]interface{}, 0, len(definitions)*len(values))
+
+		for _, definition := range definitions {
+			for _, value := range values {
+				d := deepCopyDefinition(definition)
+				if err := setDefinitionValue(d, path, deepCopyDefinition(value)); err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, d)
+			}
+		}
+
+		definitions = expanded
+	}
+
+	return definitions, nil
+}
+
+func BuildSweepSimulator(json interface{}) (*SweepSimulator, error) {
+	p := dproxy.New(json)
+
+	simType, err := p.M("Type").String()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if simType != "SweepSimulator" {
+		return nil, fmt.Errorf("Unsupported simulator type: %s", simType)
+	}
+
+	definitions := []interface{}{}
+
+	simulatorsPS := p.M("Simulators").ProxySet()
+	for i := 0; i < simulatorsPS.Len(); i++ {
+		definition, err := simulatorsPS.A(i).Value()
+		if err != nil {
+			return nil, err
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	if _, err := p.M("Grid").Value(); err == nil {
+		gridDefinitions, err := expandGridDefinitions(p.M("Grid"))
+		if err != nil {
+			return nil, err
+		}
+
+		definitions = append(definitions, gridDefinitions...)
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	if len(definitions) == 0 {
+		return nil, fmt.Errorf("SweepSimulator needs `Simulators` or `Grid`")
+	}
+
+	sweep := &SweepSimulator{
+		batch: make([]*cache.Packet, 0, sweepBatchSize),
+	}
+
+	for _, definition := range definitions {
+		sim, err := BuildSimpleCacheSimulator(definition)
+		if err != nil {
+			return nil, err
+		}
+
+		sweep.Simulators = append(sweep.Simulators, sim)
+	}
+
+	return sweep, nil
+}
@@ -0,0 +1,47 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// WarmUp is the period at the beginning of the trace, which updates cache but is excluded from statistics
+type WarmUp struct {
+	Packets int         // length of warm-up in packets, 0 if it is specified by time
+	Seconds float64     // length of warm-up in seconds of Packet.Time
+	Stat    TrafficStat // packets processed in the warm-up period
+
+	firstTime float64
+	done      bool
+}
+
+// InWarmUp returns whether the packet is in the warm-up period.
+// The packet must be given in order, and only once.
+func (w *WarmUp) InWarmUp(p *cache.Packet) bool {
+	if w.done {
+		return false
+	}
+
+	if w.Stat.Processed == 0 {
+		w.firstTime = p.Time
+	}
+
+	if w.Packets != 0 {
+		w.done = w.Packets <= w.Stat.Processed
+	} else {
+		w.done = w.Seconds <= p.Time-w.firstTime
+	}
+
+	return !w.done
+}
+
+// Ended returns whether the warm-up period has been passed
+func (w *WarmUp) Ended() bool {
+	return w.done
+}
+
+func (w *WarmUp) StatString() string {
+	return fmt.Sprintf("{\"Packets\": %d, \"Seconds\": %v, \"Processed\": %d, \"ProcessedBytes\": %d, \"Hit\": %d, \"HitBytes\": %d}",
+		w.Packets, w.Seconds, w.Stat.Processed, w.Stat.ProcessedBytes, w.Stat.Hit, w.Stat.HitBytes)
+}
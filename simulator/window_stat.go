@@ -0,0 +1,184 @@
+package simulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/kyontan/cache_simulator/cache"
+)
+
+// WindowStat writes statistics of every window (fixed number of packets or fixed seconds of Packet.Time)
+// as JSON lines or CSV
+type WindowStat struct {
+	Packets int     // length of window in packets, 0 if windows are split by time
+	Seconds float64 // length of window in seconds
+	Format  string  // "jsonl" or "csv"
+
+	writer *bufio.Writer
+	closer io.Closer
+
+	index     int
+	startTime float64
+	firstTime float64
+	started   bool
+
+	processed int
+	hit       int
+	evicted   int
+	keys      map[cache.FiveTuple]struct{}
+
+	layerBase layerCounts // of MultiLayerCache at the start of window
+}
+
+// layerCounts is a snapshot of counters of MultiLayerCache, nil fields unless the cache is MultiLayerCache
+type layerCounts struct {
+	hits     []uint // CacheHitByLayer
+	replaced []uint // CacheReplacedByLayer
+}
+
+func multiLayerCounts(c cache.Cache) layerCounts {
+	if mlc, ok := c.(*cache.MultiLayerCache); ok {
+		return layerCounts{
+			hits:     append([]uint{}, mlc.CacheHitByLayer...),
+			replaced: append([]uint{}, mlc.CacheReplacedByLayer...),
+		}
+	}
+	return layerCounts{}
+}
+
+func (w *WindowStat) writeHeader(c cache.Cache) {
+	if w.Format != "csv" {
+		return
+	}
+
+	columns := []string{"Window", "StartTime", "Processed", "Hit", "HitRate", "UniqueFlows", "Evicted"}
+	for i := range multiLayerCounts(c).hits {
+		columns = append(columns, fmt.Sprintf("LayerHit%d", i))
+	}
+
+	fmt.Fprintln(w.writer, strings.Join(columns, ","))
+}
+
+// emit writes the window, layerCountsAtEnd is multiLayerCounts() at the end of window
+func (w *WindowStat) emit(layerCountsAtEnd layerCounts) {
+	var layerHits []uint
+	for i := range layerCountsAtEnd.hits {
+		layerHits = append(layerHits, layerCountsAtEnd.hits[i]-w.layerBase.hits[i])
+	}
+
+	if layerCountsAtEnd.replaced != nil {
+		// MultiLayerCache also replaces entries on promotion of hits in lower layers,
+		// which are not returned to the simulator
+		w.evicted = 0
+		for i := range layerCountsAtEnd.replaced {
+			w.evicted += int(layerCountsAtEnd.replaced[i] - w.layerBase.replaced[i])
+		}
+	}
+
+	hitRate := float64(w.hit) / float64(w.processed)
+	if w.processed == 0 {
+		hitRate = 0
+	}
+
+	if w.Format == "csv" {
+		row := fmt.Sprintf("%d,%v,%d,%d,%v,%d,%d", w.index, w.startTime, w.processed, w.hit, hitRate, len(w.keys), w.evicted)
+		for _, x := range layerHits {
+			row += fmt.Sprintf(",%d", x)
+		}
+		fmt.Fprintln(w.writer, row)
+	} else {
+		row := fmt.Sprintf("{\"Window\": %d, \"StartTime\": %v, \"Processed\": %d, \"Hit\": %d, \"HitRate\": %v, \"UniqueFlows\": %d, \"Evicted\": %d",
+			w.index, w.startTime, w.processed, w.hit, hitRate, len(w.keys), w.evicted)
+		if layerHits != nil {
+			row += ", \"LayerHit\": ["
+			for i, x := range layerHits {
+				if i != 0 {
+					row += ", "
+				}
+				row += fmt.Sprintf("%d", x)
+			}
+			row += "]"
+		}
+		row += "}"
+		fmt.Fprintln(w.writer, row)
+	}
+
+	w.index += 1
+	w.processed = 0
+	w.hit = 0
+	w.evicted = 0
+	w.keys = map[cache.FiveTuple]struct{}{}
+	w.layerBase = layerCountsAtEnd
+}
+
+// Record is called after the cache processed the packet,
+// layerCountsBefore is multiLayerCounts(c) before the cache processes the packet
+func (w *WindowStat) Record(c cache.Cache, p *cache.Packet, f *cache.FiveTuple, hit bool, evicted int, layerCountsBefore layerCounts) {
+	if !w.started {
+		w.writeHeader(c)
+		w.started = true
+		w.firstTime = p.Time
+		w.startTime = p.Time
+		w.keys = map[cache.FiveTuple]struct{}{}
+		w.layerBase = layerCountsBefore
+	}
+
+	if w.Packets == 0 {
+		// emit windows (including empty ones) until the packet's window
+		windowIdx := int(math.Floor((p.Time - w.firstTime) / w.Seconds))
+		for w.index < windowIdx {
+			// the packet belongs to the new window, including its hit and evictions in the layer
+			w.emit(layerCountsBefore)
+			w.startTime = w.firstTime + float64(w.index)*w.Seconds
+		}
+	} else if w.processed == 0 {
+		w.startTime = p.Time
+	}
+
+	w.processed += 1
+	if hit {
+		w.hit += 1
+	}
+	w.evicted += evicted
+	w.keys[*f] = struct{}{}
+
+	if w.Packets != 0 && w.processed == w.Packets {
+		w.emit(multiLayerCounts(c))
+	}
+}
+
+// Close writes the last (partial) window and closes the output
+func (w *WindowStat) Close(c cache.Cache) error {
+	if w.processed != 0 {
+		w.emit(multiLayerCounts(c))
+	}
+
+	if err := w.writer.Flush(); err != nil {
+		return err
+	}
+
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+
+	return nil
+}
+
+func NewWindowStat(packets int, seconds float64, format string, output io.Writer) *WindowStat {
+	w := &WindowStat{
+		Packets: packets,
+		Seconds: seconds,
+		Format:  format,
+		writer:  bufio.NewWriter(output),
+	}
+
+	if closer, ok := output.(io.Closer); ok && output != os.Stdout {
+		w.closer = closer
+	}
+
+	return w
+}